@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type CheckpointStore interface {
+	Load(key string) ([]string, bool)
+	Save(key string, results []string) error
+}
+
+type checkpointRecord struct {
+	Key     string   `json:"key"`
+	Results []string `json:"results"`
+}
+
+// FileCheckpoint keeps one JSON line per completed item, every Save is synced to disk.
+type FileCheckpoint struct {
+	mu   sync.RWMutex
+	file *os.File
+	done map[string][]string
+}
+
+func OpenFileCheckpoint(path string) (*FileCheckpoint, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &FileCheckpoint{file: file, done: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec checkpointRecord
+		// the last line may be cut by a crash, such item is simply processed again
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		c.done[rec.Key] = rec.Results
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *FileCheckpoint) Load(key string) ([]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results, ok := c.done[key]
+	return results, ok
+}
+
+func (c *FileCheckpoint) Save(key string, results []string) error {
+	line, err := json.Marshal(checkpointRecord{key, results})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// newline first: a previous line may have been left unterminated by a crash
+	if _, err := c.file.Write(append(append([]byte{'\n'}, line...), '\n')); err != nil {
+		return err
+	}
+	if err := c.file.Sync(); err != nil {
+		return err
+	}
+
+	c.done[key] = results
+	return nil
+}
+
+func (c *FileCheckpoint) Close() error {
+	return c.file.Close()
+}
+
+// Checkpointed runs stages for every input item separately and saves the produced strings,
+// items found in store are not processed again, their saved results are sent instead.
+func Checkpointed(store CheckpointStore, stages ...job) job {
+	return func(in, out chan interface{}) {
+		wg := &sync.WaitGroup{}
+		guard := &panicGuard{}
+
+		for item := range in {
+			key := fmt.Sprintf("%#v", item)
+
+			if results, ok := store.Load(key); ok {
+				for _, res := range results {
+					out <- res
+				}
+				continue
+			}
+
+			wg.Add(1)
+			go checkpointWorker(store, stages, item, key, out, wg, guard)
+		}
+
+		wg.Wait()
+		guard.check()
+	}
+}
+
+func checkpointWorker(store CheckpointStore, stages []job, item interface{}, key string,
+	out chan interface{}, wg *sync.WaitGroup, guard *panicGuard) {
+	defer wg.Done()
+	defer guard.recover(item)
+
+	var results []string
+
+	jobs := make([]job, 0, len(stages)+2)
+	jobs = append(jobs, func(in, out chan interface{}) {
+		out <- item
+	})
+	jobs = append(jobs, stages...)
+	jobs = append(jobs, func(in, out chan interface{}) {
+		for res := range in {
+			results = append(results, res.(string))
+		}
+	})
+
+	if err := ExecutePipeline(jobs...); err != nil {
+		panic(err)
+	}
+
+	if err := store.Save(key, results); err != nil {
+		panic(err)
+	}
+
+	for _, res := range results {
+		out <- res
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckpointedResume(t *testing.T) {
+	defer func(crc32, md5 func(string) string) {
+		DataSignerCrc32, DataSignerMd5 = crc32, md5
+	}(DataSignerCrc32, DataSignerMd5)
+
+	var calls uint32
+	DataSignerCrc32 = func(data string) string {
+		atomic.AddUint32(&calls, 1)
+		return "c" + data
+	}
+	DataSignerMd5 = func(data string) string {
+		atomic.AddUint32(&calls, 1)
+		return "m" + data
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint")
+
+	run := func(inputData ...interface{}) string {
+		store, err := OpenFileCheckpoint(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+
+		var result string
+		err = ExecutePipeline(
+			source(inputData...),
+			Checkpointed(store, SingleHash, MultiHash),
+			CombineResults,
+			job(func(in, out chan interface{}) {
+				result = (<-in).(string)
+			}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first := run(0, 1, 1, 2)
+	if calls == 0 {
+		t.Fatal("signers were not called")
+	}
+
+	// simulate crash in the middle of writing a record
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"key":"3","resu`)
+	f.Close()
+
+	atomic.StoreUint32(&calls, 0)
+	second := run(0, 1, 1, 2)
+
+	if second != first {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", second, first)
+	}
+	if calls != 0 {
+		t.Errorf("completed items were processed again, %d signer calls", calls)
+	}
+
+	run(0, 1, 1, 2, 3)
+	if calls != 9 {
+		t.Errorf("expected only new item to be processed, %d signer calls", calls)
+	}
+}
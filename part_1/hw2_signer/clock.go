@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+// DataSignerClock is used by the DataSigner* functions and OverheatLock for all waiting
+var DataSignerClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// FakeClock is a virtual clock, time moves only by Advance and AdvanceToNext.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	at := c.now.Add(d)
+	idx := sort.Search(len(c.waiters), func(i int) bool {
+		return c.waiters[i].at.After(at)
+	})
+	c.waiters = append(c.waiters, fakeWaiter{})
+	copy(c.waiters[idx+1:], c.waiters[idx:])
+	c.waiters[idx] = fakeWaiter{at, ch}
+
+	return ch
+}
+
+// Waiters returns the number of pending Sleep and After calls
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.advanceTo(c.now.Add(d))
+}
+
+// AdvanceToNext moves the clock to the earliest pending deadline, false if there is none
+func (c *FakeClock) AdvanceToNext() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.waiters) == 0 {
+		return false
+	}
+
+	c.advanceTo(c.waiters[0].at)
+	return true
+}
+
+func (c *FakeClock) advanceTo(t time.Time) {
+	if t.After(c.now) {
+		c.now = t
+	}
+
+	fired := 0
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			break
+		}
+		w.ch <- c.now
+		fired++
+	}
+	c.waiters = c.waiters[fired:]
+}
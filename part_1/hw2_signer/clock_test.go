@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// settle is how long the number of clock waiters must stay the same before virtual time moves
+const settle = 10 * time.Millisecond
+
+// runVirtual calls f with DataSignerClock replaced by a fake one and returns the virtual time f took.
+// Only the clock wakes sleeping goroutines, so once no new Sleep or After calls appear for settle,
+// every goroutine of f sleeps or waits for a sleeping one, and the clock jumps to the next deadline.
+func runVirtual(t *testing.T, f func()) time.Duration {
+	start := time.Unix(0, 0)
+	clock := NewFakeClock(start)
+
+	orig := DataSignerClock
+	DataSignerClock = clock
+	defer func() { DataSignerClock = orig }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+
+	for {
+		waiters := clock.Waiters()
+
+		select {
+		case <-done:
+			return clock.Now().Sub(start)
+		case <-time.After(settle):
+		}
+
+		if waiters > 0 && clock.Waiters() == waiters {
+			clock.AdvanceToNext()
+		}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+
+	late := clock.After(2 * time.Second)
+	early := clock.After(time.Second)
+
+	if clock.Waiters() != 2 {
+		t.Fatalf("expected 2 waiters, got %d", clock.Waiters())
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	select {
+	case <-early:
+	default:
+		t.Error("early timer not fired")
+	}
+	select {
+	case <-late:
+		t.Error("late timer fired too soon")
+	default:
+	}
+
+	if !clock.AdvanceToNext() || !clock.Now().Equal(time.Unix(2, 0)) {
+		t.Errorf("expected clock at 2s, got %v", clock.Now())
+	}
+	if clock.AdvanceToNext() {
+		t.Error("no waiters expected")
+	}
+}
+
+func TestSignerVirtualTime(t *testing.T) {
+	testExpected := "1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542"
+	testResult := "NOT_SET"
+
+	elapsed := runVirtual(t, func() {
+		ExecutePipeline(
+			source(0, 1, 1, 2, 3, 5, 8),
+			SingleHash,
+			MultiHash,
+			CombineResults,
+			job(func(in, out chan interface{}) {
+				testResult = (<-in).(string)
+			}),
+		)
+	})
+
+	if testResult != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", testResult, testExpected)
+	}
+
+	// md5 is serialized, crc32 is fully parallel: 7*10ms + 1s + 1s
+	if expected := 2070 * time.Millisecond; elapsed != expected {
+		t.Errorf("wrong virtual execution time\nGot: %s\nExpected: %s", elapsed, expected)
+	}
+}
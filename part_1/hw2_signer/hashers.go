@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type Signer interface {
+	Sign(data string) string
+}
+
+type SignerFunc func(data string) string
+
+func (f SignerFunc) Sign(data string) string {
+	return f(data)
+}
+
+var (
+	signersMu = &sync.RWMutex{}
+	signers   = map[string]Signer{
+		// crc32 and md5 are resolved on every call so that overridden DataSigner* are used
+		"crc32":  SignerFunc(func(data string) string { return DataSignerCrc32(data) }),
+		"md5":    newSerialSigner(SignerFunc(func(data string) string { return DataSignerMd5(data) })),
+		"sha1":   hexSigner(sha1.New),
+		"sha256": hexSigner(sha256.New),
+		"fnv":    SignerFunc(fnvSign),
+		"xxhash": SignerFunc(xxhashSign),
+	}
+)
+
+// RegisterSigner makes a signer available by name, replacing any previous one.
+func RegisterSigner(name string, s Signer) {
+	signersMu.Lock()
+	defer signersMu.Unlock()
+
+	signers[name] = s
+}
+
+func LookupSigner(name string) (Signer, error) {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+
+	s, ok := signers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown signer %q", name)
+	}
+
+	return s, nil
+}
+
+func SignerNames() []string {
+	signersMu.RLock()
+	defer signersMu.RUnlock()
+
+	names := make([]string, 0, len(signers))
+	for name := range signers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// serialSigner allows only one Sign call at a time, DataSignerMd5 overheats otherwise.
+type serialSigner struct {
+	signer Signer
+	cond   *sync.Cond
+	busy   bool
+}
+
+func newSerialSigner(s Signer) *serialSigner {
+	return &serialSigner{signer: s, cond: sync.NewCond(&sync.Mutex{})}
+}
+
+func (s *serialSigner) Sign(data string) string {
+	s.cond.L.Lock()
+	for s.busy {
+		s.cond.Wait()
+	}
+	s.busy = true
+	s.cond.L.Unlock()
+
+	defer func() {
+		s.cond.L.Lock()
+		s.busy = false
+		s.cond.L.Unlock()
+		s.cond.Signal()
+	}()
+
+	return s.signer.Sign(data)
+}
+
+func hexSigner(newHash func() hash.Hash) Signer {
+	return SignerFunc(func(data string) string {
+		h := newHash()
+		h.Write([]byte(data + DataSignerSalt))
+		return hex.EncodeToString(h.Sum(nil))
+	})
+}
+
+func fnvSign(data string) string {
+	h := fnv.New64a()
+	h.Write([]byte(data + DataSignerSalt))
+	return strconv.FormatUint(h.Sum64(), 10)
+}
+
+func xxhashSign(data string) string {
+	return strconv.FormatUint(xxhash64([]byte(data+DataSignerSalt), 0), 10)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestXXHash64(t *testing.T) {
+	cases := map[string]uint64{
+		"":    0xef46db3751d8e999,
+		"abc": 0x44bc2cf5ad770999,
+		"Nobody inspects the spammish repetition": 0xfbcea83c8a378bf1,
+	}
+
+	for data, expected := range cases {
+		if got := xxhash64([]byte(data), 0); got != expected {
+			t.Errorf("xxhash64(%q)\nGot: %x\nExpected: %x", data, got, expected)
+		}
+	}
+}
+
+func TestUnknownSigner(t *testing.T) {
+	if _, err := NewSingleHash(HashConfig{Data: "crc32", Inner: "nope", Outer: "crc32"}); err == nil {
+		t.Error("expected error for unknown signer")
+	}
+	if _, err := NewMultiHash(HashConfig{Multi: "nope"}); err == nil {
+		t.Error("expected error for unknown signer")
+	}
+}
+
+func TestConfiguredSigners(t *testing.T) {
+	cfg := HashConfig{Data: "sha1", Inner: "fnv", Outer: "sha256", Multi: "xxhash"}
+
+	singleHash, err := NewSingleHash(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiHash, err := NewMultiHash(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha1, _ := LookupSigner("sha1")
+	fnv, _ := LookupSigner("fnv")
+	sha256, _ := LookupSigner("sha256")
+	xxhash, _ := LookupSigner("xxhash")
+
+	single := sha1.Sign("7") + "~" + sha256.Sign(fnv.Sign("7"))
+	expected := ""
+	for th := 0; th < TH; th++ {
+		expected += xxhash.Sign(strconv.Itoa(th) + single)
+	}
+
+	var result string
+	ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			out <- 7
+		}),
+		singleHash,
+		multiHash,
+		job(func(in, out chan interface{}) {
+			result = (<-in).(string)
+		}),
+	)
+
+	if result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+func TestRegisterSigner(t *testing.T) {
+	RegisterSigner("upper", SignerFunc(func(data string) string { return "U" + data }))
+
+	s, err := LookupSigner("upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Sign("x"); got != "Ux" {
+		t.Errorf("Got: %s, Expected: Ux", got)
+	}
+}
@@ -53,6 +53,11 @@ func TestSigner(t *testing.T) {
 	// это небольшая защита от попыток не вызывать мои функции расчета
 	// я преопределяю фукции на свои которые инкрементят локальный счетчик
 	// переопределение возможо потому что я объявил функцию как переменную, в которой лежит функция
+	// время виртуальное, см. runVirtual: тест проходит мгновенно и не зависит от нагрузки машины
+	defer func(lock, unlock func(), md5, crc32 func(string) string) {
+		OverheatLock, OverheatUnlock, DataSignerMd5, DataSignerCrc32 = lock, unlock, md5, crc32
+	}(OverheatLock, OverheatUnlock, DataSignerMd5, DataSignerCrc32)
+
 	var (
 		DataSignerSalt         string = "" // на сервере будет другое значение
 		OverheatLockCounter    uint32
@@ -65,7 +70,7 @@ func TestSigner(t *testing.T) {
 		for {
 			if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 0, 1); !swapped {
 				fmt.Println("OverheatLock happend")
-				time.Sleep(time.Second)
+				DataSignerClock.Sleep(time.Second)
 			} else {
 				break
 			}
@@ -76,7 +81,7 @@ func TestSigner(t *testing.T) {
 		for {
 			if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 1, 0); !swapped {
 				fmt.Println("OverheatUnlock happend")
-				time.Sleep(time.Second)
+				DataSignerClock.Sleep(time.Second)
 			} else {
 				break
 			}
@@ -88,7 +93,7 @@ func TestSigner(t *testing.T) {
 		defer OverheatUnlock()
 		data += DataSignerSalt
 		dataHash := fmt.Sprintf("%x", md5.Sum([]byte(data)))
-		time.Sleep(10 * time.Millisecond)
+		DataSignerClock.Sleep(10 * time.Millisecond)
 		return dataHash
 	}
 	DataSignerCrc32 = func(data string) string {
@@ -96,7 +101,7 @@ func TestSigner(t *testing.T) {
 		data += DataSignerSalt
 		crcH := crc32.ChecksumIEEE([]byte(data))
 		dataHash := strconv.FormatUint(uint64(crcH), 10)
-		time.Sleep(time.Second)
+		DataSignerClock.Sleep(time.Second)
 		return dataHash
 	}
 
@@ -122,11 +127,9 @@ func TestSigner(t *testing.T) {
 		}),
 	}
 
-	start := time.Now()
-
-	ExecutePipeline(hashSignJobs...)
-
-	end := time.Since(start)
+	end := runVirtual(t, func() {
+		ExecutePipeline(hashSignJobs...)
+	})
 
 	expectedTime := 3 * time.Second
 
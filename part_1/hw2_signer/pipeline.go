@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+type PanicPolicy int
+
+// Skip and restart apply to panics of item workers recovered by panicGuard.
+// A panic of the job itself always aborts the stage: a stateful job like CombineResults
+// would silently lose everything it had taken in if it was started again.
+const (
+	// PanicAbort stops the stage, the rest of its input is discarded.
+	PanicAbort PanicPolicy = iota
+	// PanicSkip drops the items whose workers panicked, the other items are processed.
+	PanicSkip
+	// PanicRestart runs the stage again with the items whose workers panicked, at most Pipeline.MaxRestarts times.
+	PanicRestart
+)
+
+type PanicError struct {
+	Stage int
+	// Item is the input the stage was processing, nil if unknown
+	Item  interface{}
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if e.Item == nil {
+		return fmt.Sprintf("stage %d: panic: %v", e.Stage, e.Value)
+	}
+	return fmt.Sprintf("stage %d: panic on %v: %v", e.Stage, e.Item, e.Value)
+}
+
+type PipelineError []*PanicError
+
+func (e PipelineError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type Pipeline struct {
+	Policy      PanicPolicy
+	MaxRestarts int
+}
+
+func (p *Pipeline) Execute(jobs ...job) error {
+	wg := &sync.WaitGroup{}
+
+	errs := &errorCollector{}
+
+	in := make(chan interface{})
+	close(in)
+
+	for i, job := range jobs {
+		wg.Add(1)
+		out := make(chan interface{})
+
+		s := &stage{idx: i, job: job, in: in, out: out}
+		go func() {
+			defer wg.Done()
+			p.runStage(s, errs)
+		}()
+
+		in = out
+	}
+
+	wg.Wait()
+
+	if len(errs.errs) == 0 {
+		return nil
+	}
+	return errs.errs
+}
+
+type errorCollector struct {
+	mu   sync.Mutex
+	errs PipelineError
+}
+
+func (c *errorCollector) add(errs ...*PanicError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errs = append(c.errs, errs...)
+}
+
+type stage struct {
+	idx int
+	job job
+	in  chan interface{}
+	out chan interface{}
+
+	mu      sync.Mutex
+	last    interface{}
+	taken   int
+	pending []interface{}
+}
+
+func (p *Pipeline) runStage(s *stage, errs *errorCollector) {
+	defer close(s.out)
+
+	for restarts := 0; ; restarts++ {
+		jobIn := make(chan interface{})
+		stop, done := make(chan struct{}), make(chan struct{})
+
+		s.taken = 0
+		go s.feed(jobIn, stop, done)
+
+		perrs, raw := s.run(jobIn)
+
+		close(stop)
+		<-done
+
+		if len(perrs) == 0 {
+			return
+		}
+
+		for _, perr := range perrs {
+			perr.Stage = s.idx
+			if raw && s.taken > 0 {
+				perr.Item = s.last
+			}
+		}
+		errs.add(perrs...)
+
+		// item panics are reported by panicGuard once the job has processed its input
+		switch {
+		case raw:
+		case p.Policy == PanicSkip:
+			return
+		case p.Policy == PanicRestart && restarts < p.MaxRestarts:
+			var retry []interface{}
+			for _, perr := range perrs {
+				if perr.Item != nil {
+					retry = append(retry, perr.Item)
+				}
+			}
+			s.pending = append(retry, s.pending...)
+			continue
+		}
+
+		for range s.in {
+		}
+		return
+	}
+}
+
+// run reports raw if the job itself panicked, not one of its item workers
+func (s *stage) run(in chan interface{}) (perrs PipelineError, raw bool) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case PipelineError:
+			perrs = r
+		case *PanicError:
+			perrs = PipelineError{r}
+		default:
+			perrs, raw = PipelineError{{Value: r, Stack: debug.Stack()}}, true
+		}
+	}()
+
+	s.job(in, s.out)
+
+	return nil, false
+}
+
+// feed passes items to the job one by one, so that the stage knows which item it is processing
+func (s *stage) feed(to chan interface{}, stop, done chan struct{}) {
+	defer close(done)
+	defer close(to)
+
+	for {
+		var item interface{}
+
+		s.mu.Lock()
+		if len(s.pending) > 0 {
+			item, s.pending = s.pending[0], s.pending[1:]
+			s.mu.Unlock()
+		} else {
+			s.mu.Unlock()
+
+			var ok bool
+			select {
+			case item, ok = <-s.in:
+				if !ok {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+
+		select {
+		case to <- item:
+			s.mu.Lock()
+			s.last = item
+			s.taken++
+			s.mu.Unlock()
+		case <-stop:
+			s.mu.Lock()
+			s.pending = append([]interface{}{item}, s.pending...)
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// panicGuard recovers panics of item workers, a stage re-panics with them once its input is processed
+type panicGuard struct {
+	mu   sync.Mutex
+	errs PipelineError
+}
+
+func (g *panicGuard) recover(item interface{}) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	perr, ok := r.(*PanicError)
+	if !ok {
+		perr = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+	perr.Item = item
+
+	g.mu.Lock()
+	g.errs = append(g.errs, perr)
+	g.mu.Unlock()
+}
+
+func (g *panicGuard) check() {
+	if len(g.errs) > 0 {
+		panic(g.errs)
+	}
+}
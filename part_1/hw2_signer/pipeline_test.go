@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func source(items ...interface{}) job {
+	return func(in, out chan interface{}) {
+		for _, item := range items {
+			out <- item
+		}
+	}
+}
+
+func collect(res *[]interface{}) job {
+	return func(in, out chan interface{}) {
+		for item := range in {
+			*res = append(*res, item)
+		}
+	}
+}
+
+func failOn(bad interface{}, fails *int) job {
+	return func(in, out chan interface{}) {
+		for item := range in {
+			if item == bad && *fails > 0 {
+				*fails--
+				panic("bad item")
+			}
+			out <- item
+		}
+	}
+}
+
+// failOnItem panics in a guarded item worker, like SingleHash and MultiHash do
+func failOnItem(bad interface{}, fails *int) job {
+	return func(in, out chan interface{}) {
+		guard := &panicGuard{}
+		for item := range in {
+			func() {
+				defer guard.recover(item)
+				if item == bad && *fails > 0 {
+					*fails--
+					panic("bad item")
+				}
+				out <- item
+			}()
+		}
+		guard.check()
+	}
+}
+
+func TestPipelineAbortOnPanic(t *testing.T) {
+	var res []interface{}
+	fails := 1
+
+	err := ExecutePipeline(source(1, 2, 3), failOn(2, &fails), collect(&res))
+
+	var perrs PipelineError
+	if !errors.As(err, &perrs) || len(perrs) != 1 {
+		t.Fatalf("expected one panic error, got %v", err)
+	}
+	if perrs[0].Stage != 1 || perrs[0].Item != 2 || len(perrs[0].Stack) == 0 {
+		t.Errorf("wrong panic error %+v", perrs[0])
+	}
+	if len(res) != 1 || res[0] != 1 {
+		t.Errorf("expected only items before panic, got %v", res)
+	}
+}
+
+func TestPipelineSkipOnPanic(t *testing.T) {
+	var res []interface{}
+	fails := 1
+
+	p := &Pipeline{Policy: PanicSkip}
+	err := p.Execute(source(1, 2, 3), failOnItem(2, &fails), collect(&res))
+
+	if err == nil {
+		t.Error("expected panic to be reported")
+	}
+	if len(res) != 2 || res[0] != 1 || res[1] != 3 {
+		t.Errorf("expected 2 to be skipped, got %v", res)
+	}
+}
+
+func TestPipelineRestartOnPanic(t *testing.T) {
+	var res []interface{}
+	fails := 2
+
+	p := &Pipeline{Policy: PanicRestart, MaxRestarts: 2}
+	err := p.Execute(source(1, 2, 3), failOnItem(2, &fails), collect(&res))
+
+	var perrs PipelineError
+	if !errors.As(err, &perrs) || len(perrs) != 2 {
+		t.Fatalf("expected two panic errors, got %v", err)
+	}
+	// failed items are retried after the rest of the input
+	if len(res) != 3 || res[0] != 1 || res[1] != 3 || res[2] != 2 {
+		t.Errorf("expected all items after restarts, got %v", res)
+	}
+}
+
+func TestPipelineRestartLimit(t *testing.T) {
+	var res []interface{}
+	fails := 5
+
+	p := &Pipeline{Policy: PanicRestart, MaxRestarts: 1}
+	err := p.Execute(source(1, 2, 3), failOnItem(2, &fails), collect(&res))
+
+	var perrs PipelineError
+	if !errors.As(err, &perrs) || len(perrs) != 2 {
+		t.Fatalf("expected two panic errors, got %v", err)
+	}
+	if len(res) != 2 || res[0] != 1 || res[1] != 3 {
+		t.Errorf("expected 2 to be given up, got %v", res)
+	}
+}
+
+func TestPipelineJobPanicAborts(t *testing.T) {
+	for _, p := range []*Pipeline{{Policy: PanicSkip}, {Policy: PanicRestart, MaxRestarts: 3}} {
+		var res []interface{}
+
+		// CombineResults panics on 1 after taking "a" and "c", starting it again would lose them
+		err := p.Execute(source("a", "c", 1, "b"), CombineResults, collect(&res))
+
+		var perrs PipelineError
+		if !errors.As(err, &perrs) || len(perrs) != 1 || perrs[0].Stage != 1 || perrs[0].Item != 1 {
+			t.Fatalf("policy %d: expected one panic error on 1, got %v", p.Policy, err)
+		}
+		if len(res) != 0 {
+			t.Errorf("policy %d: expected stage to be aborted, got %v", p.Policy, res)
+		}
+	}
+}
+
+func TestPipelineItemWorkerPanic(t *testing.T) {
+	defer func(crc32, md5 func(string) string) {
+		DataSignerCrc32, DataSignerMd5 = crc32, md5
+	}(DataSignerCrc32, DataSignerMd5)
+
+	DataSignerCrc32 = func(data string) string { return data }
+	DataSignerMd5 = func(data string) string { return data }
+
+	var res []interface{}
+
+	p := &Pipeline{Policy: PanicSkip}
+	err := p.Execute(source(1, "two", 3), SingleHash, collect(&res))
+
+	var perrs PipelineError
+	if !errors.As(err, &perrs) || len(perrs) != 1 {
+		t.Fatalf("expected one panic error, got %v", err)
+	}
+	if perrs[0].Stage != 1 || perrs[0].Item != "two" {
+		t.Errorf("wrong panic error %+v", perrs[0])
+	}
+
+	got := make([]string, 0, len(res))
+	for _, r := range res {
+		got = append(got, r.(string))
+	}
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "1~1" || got[1] != "3~3" {
+		t.Errorf("expected other items to be hashed, got %v", got)
+	}
+}
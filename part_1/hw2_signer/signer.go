@@ -1,104 +1,164 @@
 package main
 
 import (
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const TH = 6
 
-var ExecutePipeline = func(jobs ...job) {
-	wg := &sync.WaitGroup{}
-	defer wg.Wait()
+var ExecutePipeline = func(jobs ...job) error {
+	return (&Pipeline{}).Execute(jobs...)
+}
 
-	in := make(chan interface{})
+// HashConfig names the signers used by every hashing step:
+// SingleHash is Data(x) + "~" + Outer(Inner(x)), MultiHash joins Multi(th + x) for th in [0, TH).
+type HashConfig struct {
+	Data  string
+	Inner string
+	Outer string
+	Multi string
+}
 
-	for _, job := range jobs {
-		wg.Add(1)
-		out := make(chan interface{})
+var DefaultHashConfig = HashConfig{
+	Data:  "crc32",
+	Inner: "md5",
+	Outer: "crc32",
+	Multi: "crc32",
+}
 
-		go jobWorker(job, in, out, wg)
+var SingleHash = func(in, out chan interface{}) {
+	mustJob(NewSingleHash(DefaultHashConfig))(in, out)
+}
 
-		in = out
+var MultiHash = func(in, out chan interface{}) {
+	mustJob(NewMultiHash(DefaultHashConfig))(in, out)
+}
+
+func mustJob(j job, err error) job {
+	if err != nil {
+		panic(err)
 	}
+	return j
 }
 
-func jobWorker(job job, in, out chan interface{}, wg *sync.WaitGroup) {
-	defer wg.Done()
-	defer close(out)
+type singleHasher struct {
+	data, inner, outer Signer
+}
+
+func NewSingleHash(cfg HashConfig) (job, error) {
+	var (
+		h   singleHasher
+		err error
+	)
 
-	job(in, out)
+	if h.data, err = LookupSigner(cfg.Data); err != nil {
+		return nil, err
+	}
+	if h.inner, err = LookupSigner(cfg.Inner); err != nil {
+		return nil, err
+	}
+	if h.outer, err = LookupSigner(cfg.Outer); err != nil {
+		return nil, err
+	}
+
+	return h.run, nil
 }
 
-var SingleHash = func(in, out chan interface{}) {
+func (h singleHasher) run(in, out chan interface{}) {
 	wg := &sync.WaitGroup{}
-	defer wg.Wait()
-
-	mu := &sync.Mutex{}
+	guard := &panicGuard{}
 
 	for i := range in {
 		wg.Add(1)
-		go singleHashWorker(i, out, wg, mu)
+		go h.worker(i, out, wg, guard)
 	}
+
+	wg.Wait()
+	guard.check()
 }
 
-func singleHashWorker(in interface{}, out chan interface{}, wg *sync.WaitGroup, mu *sync.Mutex) {
+func (h singleHasher) worker(in interface{}, out chan interface{}, wg *sync.WaitGroup, guard *panicGuard) {
 	defer wg.Done()
+	defer guard.recover(in)
 
 	data := strconv.Itoa(in.(int))
 
-	mu.Lock()
-	md5 := DataSignerMd5(data)
-	mu.Unlock()
-
 	dataChan := make(chan string)
-	go crc32Parallel(data, dataChan)
-	signerCrc32 := DataSignerCrc32(md5)
-	crc32Data := <-dataChan
+	go signParallel(h.data, data, dataChan)
+	signed := h.outer.Sign(h.inner.Sign(data))
+	dataSigned := <-dataChan
 
-	out <- crc32Data + "~" + signerCrc32
+	out <- dataSigned + "~" + signed
 }
 
-func crc32Parallel(data string, out chan string) {
-	out <- DataSignerCrc32(data)
+func signParallel(s Signer, data string, out chan string) {
+	out <- s.Sign(data)
 }
 
-var MultiHash = func(in, out chan interface{}) {
+type multiHasher struct {
+	signer Signer
+}
+
+func NewMultiHash(cfg HashConfig) (job, error) {
+	s, err := LookupSigner(cfg.Multi)
+	if err != nil {
+		return nil, err
+	}
+
+	return multiHasher{s}.run, nil
+}
+
+func (h multiHasher) run(in, out chan interface{}) {
 	wg := &sync.WaitGroup{}
-	defer wg.Wait()
+	guard := &panicGuard{}
 
 	for i := range in {
 		wg.Add(1)
-		go multiHashWorker(i.(string), out, wg)
+		go h.worker(i, out, wg, guard)
 	}
+
+	wg.Wait()
+	guard.check()
 }
 
-func multiHashWorker(in string, out chan interface{}, wg *sync.WaitGroup) {
+func (h multiHasher) worker(item interface{}, out chan interface{}, wg *sync.WaitGroup, guard *panicGuard) {
 	defer wg.Done()
+	defer guard.recover(item)
+
+	in := item.(string)
 
 	mu := &sync.Mutex{}
-	crc32 := &sync.WaitGroup{}
+	signed := &sync.WaitGroup{}
+	signedGuard := &panicGuard{}
 
 	strs := make([]string, TH)
 
 	for i := 0; i < TH; i++ {
-		crc32.Add(1)
+		signed.Add(1)
 		data := strconv.Itoa(i) + in
 
 		go func(arr []string, data string, idx int, wg *sync.WaitGroup, mu *sync.Mutex) {
 			defer wg.Done()
+			defer signedGuard.recover(nil)
 
-			data = DataSignerCrc32(data)
+			data = h.signer.Sign(data)
 
 			mu.Lock()
 			arr[idx] = data
 			mu.Unlock()
-		}(strs, data, i, crc32, mu)
+		}(strs, data, i, signed, mu)
 	}
 
-	crc32.Wait()
+	signed.Wait()
+
+	if len(signedGuard.errs) > 0 {
+		panic(signedGuard.errs[0])
+	}
 
 	res := strings.Join(strs, "")
 
@@ -112,8 +172,65 @@ var CombineResults = func(in, out chan interface{}) {
 		array = append(array, i.(string))
 	}
 
-	sort.Strings(array)
-	res := strings.Join(array, "_")
+	out <- combine(array)
+}
 
-	out <- res
+// CombineResultsEvery emits combined results of every n items, the rest is emitted on close
+func CombineResultsEvery(n int) job {
+	if n <= 0 {
+		panic(fmt.Sprintf("CombineResultsEvery: window size must be positive, got %d", n))
+	}
+
+	return func(in, out chan interface{}) {
+		array := make([]string, 0, n)
+
+		for i := range in {
+			array = append(array, i.(string))
+
+			if len(array) == n {
+				out <- combine(array)
+				array = array[:0]
+			}
+		}
+
+		if len(array) > 0 {
+			out <- combine(array)
+		}
+	}
+}
+
+// CombineResultsWindow emits combined results of items received during every d, empty windows are skipped
+func CombineResultsWindow(d time.Duration) job {
+	if d <= 0 {
+		panic(fmt.Sprintf("CombineResultsWindow: window duration must be positive, got %s", d))
+	}
+
+	return func(in, out chan interface{}) {
+		var array []string
+
+		tick := DataSignerClock.After(d)
+		for {
+			select {
+			case i, ok := <-in:
+				if !ok {
+					if len(array) > 0 {
+						out <- combine(array)
+					}
+					return
+				}
+				array = append(array, i.(string))
+			case <-tick:
+				if len(array) > 0 {
+					out <- combine(array)
+					array = nil
+				}
+				tick = DataSignerClock.After(d)
+			}
+		}
+	}
+}
+
+func combine(array []string) string {
+	sort.Strings(array)
+	return strings.Join(array, "_")
 }
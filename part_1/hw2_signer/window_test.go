@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCombineResultsEvery(t *testing.T) {
+	var res []interface{}
+
+	ExecutePipeline(
+		source("c", "a", "b", "e", "d"),
+		CombineResultsEvery(2),
+		collect(&res),
+	)
+
+	expected := []interface{}{"a_c", "b_e", "d"}
+	if len(res) != len(expected) {
+		t.Fatalf("results not match\nGot: %v\nExpected: %v", res, expected)
+	}
+	for i := range expected {
+		if res[i] != expected[i] {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", res, expected)
+		}
+	}
+}
+
+func TestCombineResultsEveryInvalidSize(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CombineResultsEvery(%d) did not panic", n)
+				}
+			}()
+			CombineResultsEvery(n)
+		}()
+	}
+}
+
+func TestCombineResultsWindowInvalidDuration(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CombineResultsWindow(%s) did not panic", d)
+				}
+			}()
+			CombineResultsWindow(d)
+		}()
+	}
+}
+
+func TestCombineResultsWindow(t *testing.T) {
+	var res []interface{}
+
+	runVirtual(t, func() {
+		ExecutePipeline(
+			job(func(in, out chan interface{}) {
+				out <- "b"
+				out <- "a"
+				DataSignerClock.Sleep(1500 * time.Millisecond)
+				out <- "c"
+				DataSignerClock.Sleep(3 * time.Second)
+				out <- "e"
+				out <- "d"
+			}),
+			CombineResultsWindow(time.Second),
+			collect(&res),
+		)
+	})
+
+	expected := []interface{}{"a_b", "c", "d_e"}
+	if len(res) != len(expected) {
+		t.Fatalf("results not match\nGot: %v\nExpected: %v", res, expected)
+	}
+	for i := range expected {
+		if res[i] != expected[i] {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", res, expected)
+		}
+	}
+}
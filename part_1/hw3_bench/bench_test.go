@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"coursera/hw3/usergen"
+)
+
+// budgets are checked on demand only, the run takes several seconds and allocations differ under -race:
+//
+//	go test -run TestBenchmarkBudgets -budgets
+//	BENCH_BUDGETS=1 go test -run TestBenchmarkBudgets
+//	go test -run TestBenchmarkBudgets -synthetic-users 5000 -update-budgets
+
+var (
+	syntheticUsers = flag.Int("synthetic-users", 2000, "number of users in the synthetic benchmark file")
+	checkBudgets   = flag.Bool("budgets", false, "check "+budgetsPath+", also enabled by BENCH_BUDGETS=1")
+	updateBudgets  = flag.Bool("update-budgets", false, "rewrite "+budgetsPath+" with measured results")
+)
+
+const budgetsPath = "testdata/budgets.json"
+
+type searchVariant struct {
+	name string
+	run  func(path, indexPath string) error
+}
+
+var benchQuery = And(HasBrowser("Android"), HasBrowser("MSIE"))
+
+var searchVariants = []searchVariant{
+	{"Fast", func(path, indexPath string) error {
+		return SearchFile(path, benchQuery, NewTextWriter(ioutil.Discard), 1)
+	}},
+	{"Parallel", func(path, indexPath string) error {
+		return SearchFile(path, benchQuery, NewTextWriter(ioutil.Discard), 4)
+	}},
+	{"Tolerant", func(path, indexPath string) error {
+		return SearchFileTolerant(path, benchQuery, NewTextWriter(ioutil.Discard), 1, &Rejects{})
+	}},
+	{"Indexed", func(path, indexPath string) error {
+		return SearchIndexed(path, indexPath, benchQuery, NewTextWriter(ioutil.Discard))
+	}},
+}
+
+// budget keeps time as a share of SlowSearch measured in the same run, absolute time depends on the machine
+type budget struct {
+	SlowShare   float64 `json:"slow_share"`
+	BytesPerOp  int64   `json:"bytes_per_op"`
+	AllocsPerOp int64   `json:"allocs_per_op"`
+}
+
+type budgets struct {
+	Users int `json:"users"`
+	// allowed relative growth, time is noisy and gets a wider tolerance
+	NsTolerance     float64           `json:"ns_tolerance"`
+	MemoryTolerance float64           `json:"memory_tolerance"`
+	Variants        map[string]budget `json:"variants"`
+}
+
+func syntheticFile(tb testing.TB, users int) (string, string) {
+	dir := tb.TempDir()
+	path := filepath.Join(dir, "users.txt")
+
+	file, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err := usergen.Generate(file, 1, 0, users); err != nil {
+		tb.Fatal(err)
+	}
+
+	return path, filepath.Join(dir, "users.idx")
+}
+
+func BenchmarkSynthetic(b *testing.B) {
+	path, indexPath := syntheticFile(b, *syntheticUsers)
+
+	for _, v := range searchVariants {
+		v := v
+		b.Run(v.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := v.run(path, indexPath); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func measure(run func() error) testing.BenchmarkResult {
+	return testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := run(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestBenchmarkBudgets(t *testing.T) {
+	if !*checkBudgets && !*updateBudgets && os.Getenv("BENCH_BUDGETS") != "1" {
+		t.Skip("budgets are checked with -budgets or BENCH_BUDGETS=1")
+	}
+
+	var stored budgets
+	data, err := os.ReadFile(budgetsPath)
+	if err == nil {
+		err = json.Unmarshal(data, &stored)
+	}
+	if err != nil && !*updateBudgets {
+		t.Fatalf("cant load budgets: %s", err)
+	}
+
+	users := *syntheticUsers
+	if !*updateBudgets && stored.Users != 0 {
+		users = stored.Users
+	}
+	path, indexPath := syntheticFile(t, users)
+
+	slow := measure(func() error {
+		SlowSearch(ioutil.Discard)
+		return nil
+	})
+	t.Logf("%-10s %s %s", "Slow", slow.String(), slow.MemString())
+
+	measured := make(map[string]budget, len(searchVariants))
+	for _, v := range searchVariants {
+		v := v
+		res := measure(func() error { return v.run(path, indexPath) })
+		measured[v.name] = budget{
+			SlowShare:   float64(res.NsPerOp()) / float64(slow.NsPerOp()),
+			BytesPerOp:  res.AllocedBytesPerOp(),
+			AllocsPerOp: res.AllocsPerOp(),
+		}
+		t.Logf("%-10s %s %s", v.name, res.String(), res.MemString())
+	}
+
+	if *updateBudgets {
+		stored.Users = users
+		if stored.NsTolerance == 0 {
+			stored.NsTolerance, stored.MemoryTolerance = 1, 0.1
+		}
+		stored.Variants = measured
+
+		data, err := json.MarshalIndent(stored, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(budgetsPath, append(data, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	for _, v := range searchVariants {
+		b, ok := stored.Variants[v.name]
+		if !ok {
+			t.Errorf("%s: no budget, run with -update-budgets", v.name)
+			continue
+		}
+
+		m := measured[v.name]
+		if m.SlowShare > b.SlowShare*(1+stored.NsTolerance) {
+			t.Errorf("%s: ns/op regressed\nGot: %.3f of SlowSearch\nBudget: %.3f (+%.0f%%)", v.name, m.SlowShare, b.SlowShare, stored.NsTolerance*100)
+		}
+		checkBudget(t, v.name, "B/op", m.BytesPerOp, b.BytesPerOp, stored.MemoryTolerance)
+		checkBudget(t, v.name, "allocs/op", m.AllocsPerOp, b.AllocsPerOp, stored.MemoryTolerance)
+	}
+}
+
+func checkBudget(t *testing.T, variant, metric string, got, limit int64, tolerance float64) {
+	if float64(got) > float64(limit)*(1+tolerance) {
+		t.Errorf("%s: %s regressed\nGot: %d\nBudget: %d (+%.0f%%)", variant, metric, got, limit, tolerance*100)
+	}
+}
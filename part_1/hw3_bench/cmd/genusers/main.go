@@ -0,0 +1,88 @@
+// genusers writes synthetic users in the data/users.txt format, run it from the hw3_bench module:
+//
+//	go run ./cmd/genusers -size 1GB -seed 42 -out users.txt
+//
+// The same seed and size always produce the same file.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"coursera/hw3/usergen"
+)
+
+// parseSize accepts plain bytes or KB, MB, GB suffixes
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	mult := int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(s), u.suffix) {
+			s, mult = s[:len(s)-len(u.suffix)], u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return n * mult, nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	seed := flag.Int64("seed", 1, "random seed")
+	sizeFlag := flag.String("size", "", "target file size, e.g. 500MB or 1GB")
+	count := flag.Int("users", 0, "number of users")
+	outPath := flag.String("out", "", "output file, stdout by default")
+	flag.Parse()
+
+	var size int64
+	if *sizeFlag != "" {
+		var err error
+		if size, err = parseSize(*sizeFlag); err != nil {
+			return err
+		}
+	}
+	if size == 0 && *count == 0 {
+		return errors.New("-size or -users is required")
+	}
+
+	if *outPath == "" {
+		_, err := usergen.Generate(os.Stdout, *seed, size, *count)
+		return err
+	}
+	return generateFile(*outPath, *seed, size, *count)
+}
+
+// generateFile removes the partially written file on errors
+func generateFile(path string, seed, size int64, count int) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = usergen.Generate(out, seed, size, count)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
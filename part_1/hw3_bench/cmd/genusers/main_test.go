@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]int64{"100": 100, "2KB": 2 << 10, "10mb": 10 << 20, "1GB": 1 << 30}
+	for s, expected := range cases {
+		if got, err := parseSize(s); err != nil || got != expected {
+			t.Errorf("%s: got %d %v, expected %d", s, got, err, expected)
+		}
+	}
+
+	if _, err := parseSize("lots"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestGenerateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.txt")
+	if err := generateFile(path, 1, 0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := os.Stat(path); err != nil || stat.Size() == 0 {
+		t.Errorf("users are not written: %v", err)
+	}
+
+	bad := filepath.Join(t.TempDir(), "missing", "users.txt")
+	if err := generateFile(bad, 1, 0, 10); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -2,22 +2,26 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/json"
-	"fmt"
 	"github.com/mailru/easyjson"
 	"github.com/mailru/easyjson/jlexer"
 	"github.com/mailru/easyjson/jwriter"
 	"io"
 	"os"
 	"strconv"
-	"strings"
 	"sync"
 )
 
 type User struct {
 	Browsers []string
+	Company  string
+	Country  string
 	Email    string
+	Job      string
 	Name     string
+	Phone    string
 }
 
 var userPool = sync.Pool{
@@ -27,78 +31,166 @@ var userPool = sync.Pool{
 }
 
 func FastSearch(out io.Writer) {
-	file, err := os.Open(filePath)
-	if err != nil {
+	if err := QuerySearch(out, And(HasBrowser("Android"), HasBrowser("MSIE"))); err != nil {
+		panic(err)
+	}
+}
+
+// FastSearchTolerant is FastSearch skipping malformed records, they are summarized after the report
+func FastSearchTolerant(out io.Writer) {
+	rejects := &Rejects{}
+	if err := SearchFileTolerant(filePath, And(HasBrowser("Android"), HasBrowser("MSIE")), NewTextWriter(out), 1, rejects); err != nil {
+		panic(err)
+	}
+	if err := rejects.WriteSummary(out); err != nil {
 		panic(err)
 	}
+}
+
+// QuerySearch writes the FastSearch report for users matching q
+func QuerySearch(out io.Writer, q Query) error {
+	return SearchFile(filePath, q, NewTextWriter(out), 1)
+}
+
+// Search reads users from r, gzip-compressed input is detected by its magic bytes
+func Search(r io.Reader, q Query, w ResultWriter) error {
+	return SearchTolerant(r, q, w, nil)
+}
+
+// SearchTolerant is Search skipping malformed records and users with invalid email into rejects,
+// nil rejects makes the search fail on the first malformed record
+func SearchTolerant(r io.Reader, q Query, w ResultWriter, rejects *Rejects) error {
+	cq, err := Compile(q)
+	if err != nil {
+		return err
+	}
+
+	r, err = decompress(r)
+	if err != nil {
+		return err
+	}
+
+	w = redacting(w)
+	mask := cq.newBrowserMask()
+	if _, err := cq.scan(r, mask, rejects, w.WriteUser); err != nil {
+		return err
+	}
+
+	return w.Finish(mask.unique)
+}
+
+// SearchFile is Search over the file at path, plain files are split between workers if there are more than one
+func SearchFile(path string, q Query, w ResultWriter, workers int) error {
+	return SearchFileTolerant(path, q, w, workers, nil)
+}
+
+func SearchFileTolerant(path string, q Query, w ResultWriter, workers int, rejects *Rejects) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
 	defer file.Close()
 
-	var count = 0
-	var i = -1
+	if workers <= 1 {
+		return SearchTolerant(file, q, w, rejects)
+	}
 
-	var users = make([]string, 0, 100)
-	var checked = make(map[string]bool, 256)
+	gzipped, err := isGzip(file)
+	if err != nil {
+		return err
+	}
+	if gzipped {
+		return SearchTolerant(file, q, w, rejects)
+	}
 
-	reader := bufio.NewReader(file)
-	for {
-		line, err := reader.ReadSlice('\n')
-		if err != nil {
-			break
-		}
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
 
-		var user = userPool.Get().(*User)
-		err = user.UnmarshalJSON([]byte(line))
-		if err != nil {
-			panic(err)
-		}
-		userPool.Put(user)
+	cq, err := Compile(q)
+	if err != nil {
+		return err
+	}
 
-		var userAndroid = false
-		var userMSIE = false
+	w = redacting(w)
+	count, err := cq.scanParallel(file, stat.Size(), workers, defaultChunkSize, rejects, w.WriteUser)
+	if err != nil {
+		return err
+	}
 
-		for _, browser := range user.Browsers {
-			isBrowserChecked, found := checked[browser]
+	return w.Finish(count)
+}
 
-			var MSIE = false
-			var Android = false
+var gzipMagic = []byte{0x1f, 0x8b}
 
-			if !found {
-				MSIE = strings.Contains(browser, "MSIE")
-				Android = strings.Contains(browser, "Android")
-				checked[browser] = MSIE || Android
-			} else {
-				if isBrowserChecked {
-					MSIE = strings.Contains(browser, "MSIE")
-					Android = strings.Contains(browser, "Android")
-				} else {
-					MSIE = false
-					Android = false
-				}
-			}
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
 
-			userMSIE = userMSIE || Android
-			userAndroid = userAndroid || MSIE
+	return gzip.NewReader(br)
+}
+
+func isGzip(r io.ReaderAt) (bool, error) {
+	magic := make([]byte, len(gzipMagic))
+
+	n, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	return bytes.Equal(magic[:n], gzipMagic), nil
+}
 
-			if !found && (Android || MSIE) {
-				count++
+// scan calls found for every matching user, browsers are accounted in mask, returns the number of lines.
+// Malformed records fail the scan with *RecordError unless rejects is set.
+func (q *CompiledQuery) scan(r io.Reader, mask *browserMask, rejects *Rejects, found func(i int, user *User)) (int, error) {
+	var user = userPool.Get().(*User)
+	defer userPool.Put(user)
+
+	var i int
+	var long []byte
+
+	reader := bufio.NewReader(r)
+	for ; ; i++ {
+		line, err := readLine(reader, &long)
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				break
 			}
+			return i, err
 		}
 
-		i++
-		if !(userAndroid && userMSIE) {
-			continue
+		*user = User{Browsers: user.Browsers[:0]}
+		if jsonErr := user.UnmarshalProjection(line, q.fields); jsonErr != nil {
+			if rejects == nil {
+				return i, &RecordError{i, jsonErr}
+			}
+			if err := rejects.add(RejectedRecord{i, jsonErr.Error(), string(bytes.TrimRight(line, "\r\n"))}); err != nil {
+				return i, err
+			}
+		} else if rejects != nil && !validEmail(user.Email) {
+			if err := rejects.add(RejectedRecord{i, "invalid email " + strconv.Quote(user.Email), string(bytes.TrimRight(line, "\r\n"))}); err != nil {
+				return i, err
+			}
+		} else if q.match(user, mask.of(user.Browsers)) {
+			found(i, user)
 		}
 
-		var email = strings.Split(user.Email, "@")
-		users = append(
-			users,
-			"["+strconv.Itoa(i)+"] "+user.Name+" <"+email[0]+" [at] "+email[1]+">",
-		)
-
+		if err == io.EOF {
+			i++
+			break
+		}
 	}
 
-	fmt.Fprintln(out, "found users:\n"+strings.Join(users, "\n")+"\n")
-	fmt.Fprintln(out, "Total unique browsers", count)
+	return i, nil
 }
 
 // suppress unused package warning
@@ -128,10 +220,6 @@ func easyjson89aae3efDecodeFast(in *jlexer.Lexer, out *User) {
 			continue
 		}
 		switch key {
-		case "name":
-			out.Name = string(in.String())
-		case "email":
-			out.Email = string(in.String())
 		case "browsers":
 			if in.IsNull() {
 				in.Skip()
@@ -155,6 +243,18 @@ func easyjson89aae3efDecodeFast(in *jlexer.Lexer, out *User) {
 				}
 				in.Delim(']')
 			}
+		case "company":
+			out.Company = string(in.String())
+		case "country":
+			out.Country = string(in.String())
+		case "email":
+			out.Email = string(in.String())
+		case "job":
+			out.Job = string(in.String())
+		case "name":
+			out.Name = string(in.String())
+		case "phone":
+			out.Phone = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -169,19 +269,9 @@ func easyjson89aae3efEncodeFast(out *jwriter.Writer, in User) {
 	out.RawByte('{')
 	first := true
 	_ = first
-	{
-		const prefix string = ",\"name\":"
-		out.RawString(prefix[1:])
-		out.String(string(in.Name))
-	}
-	{
-		const prefix string = ",\"email\":"
-		out.RawString(prefix)
-		out.String(string(in.Email))
-	}
 	{
 		const prefix string = ",\"browsers\":"
-		out.RawString(prefix)
+		out.RawString(prefix[1:])
 		if in.Browsers == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 			out.RawString("null")
 		} else {
@@ -195,6 +285,36 @@ func easyjson89aae3efEncodeFast(out *jwriter.Writer, in User) {
 			out.RawByte(']')
 		}
 	}
+	{
+		const prefix string = ",\"company\":"
+		out.RawString(prefix)
+		out.String(string(in.Company))
+	}
+	{
+		const prefix string = ",\"country\":"
+		out.RawString(prefix)
+		out.String(string(in.Country))
+	}
+	{
+		const prefix string = ",\"email\":"
+		out.RawString(prefix)
+		out.String(string(in.Email))
+	}
+	{
+		const prefix string = ",\"job\":"
+		out.RawString(prefix)
+		out.String(string(in.Job))
+	}
+	{
+		const prefix string = ",\"name\":"
+		out.RawString(prefix)
+		out.String(string(in.Name))
+	}
+	{
+		const prefix string = ",\"phone\":"
+		out.RawString(prefix)
+		out.String(string(in.Phone))
+	}
 	out.RawByte('}')
 }
 
@@ -216,3 +336,8 @@ func (v *User) UnmarshalJSON(data []byte) error {
 	easyjson89aae3efDecodeFast(&r, v)
 	return r.Error()
 }
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *User) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson89aae3efDecodeFast(l, v)
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var indexMagic = []byte("UIDX\x01")
+
+var errStaleIndex = errors.New("index is stale")
+
+// Index maps every browser to the users having it, users are referenced by line number.
+type Index struct {
+	Source  string
+	Size    int64
+	ModTime int64
+	// Offsets are line start offsets, the last element is the source size
+	Offsets  []int64
+	Browsers map[string][]int
+}
+
+// OpenIndex loads the index of source from indexPath, it is rebuilt and saved if missing or stale
+func OpenIndex(source, indexPath string) (*Index, error) {
+	stat, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	ix, err := loadIndex(source, indexPath, stat)
+	if err == nil {
+		return ix, nil
+	}
+
+	ix, err = BuildIndex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ix.Save(indexPath); err != nil {
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+func BuildIndex(source string) (*Index, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{
+		Source:   source,
+		Size:     stat.Size(),
+		ModTime:  stat.ModTime().UnixNano(),
+		Browsers: make(map[string][]int, 256),
+	}
+
+	var user = userPool.Get().(*User)
+	defer userPool.Put(user)
+
+	var offset int64
+	var long []byte
+
+	reader := bufio.NewReader(file)
+	for i := 0; ; i++ {
+		line, err := readLine(reader, &long)
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		*user = User{Browsers: user.Browsers[:0]}
+		if err := user.UnmarshalProjection(line, FieldBrowsers); err != nil {
+			return nil, &RecordError{i, err}
+		}
+
+		ix.Offsets = append(ix.Offsets, offset)
+		offset += int64(len(line))
+
+		for _, browser := range user.Browsers {
+			lines := ix.Browsers[browser]
+			if len(lines) == 0 || lines[len(lines)-1] != i {
+				ix.Browsers[browser] = append(lines, i)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+	ix.Offsets = append(ix.Offsets, offset)
+
+	return ix, nil
+}
+
+// Save writes the index atomically, numbers are uvarints and sorted sequences are delta-encoded
+func (ix *Index) Save(indexPath string) error {
+	buf := append([]byte(nil), indexMagic...)
+	buf = binary.AppendUvarint(buf, uint64(ix.Size))
+	buf = binary.AppendVarint(buf, ix.ModTime)
+
+	buf = appendDeltas(buf, ix.Offsets)
+
+	browsers := make([]string, 0, len(ix.Browsers))
+	for browser := range ix.Browsers {
+		browsers = append(browsers, browser)
+	}
+	sort.Strings(browsers)
+
+	buf = binary.AppendUvarint(buf, uint64(len(browsers)))
+	for _, browser := range browsers {
+		buf = binary.AppendUvarint(buf, uint64(len(browser)))
+		buf = append(buf, browser...)
+
+		lines := ix.Browsers[browser]
+		ids := make([]int64, len(lines))
+		for i, line := range lines {
+			ids[i] = int64(line)
+		}
+		buf = appendDeltas(buf, ids)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(indexPath), filepath.Base(indexPath)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), indexPath)
+}
+
+func appendDeltas(buf []byte, values []int64) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(values)))
+
+	var prev int64
+	for _, v := range values {
+		buf = binary.AppendUvarint(buf, uint64(v-prev))
+		prev = v
+	}
+	return buf
+}
+
+func loadIndex(source, indexPath string, stat os.FileInfo) (*Index, error) {
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, indexMagic) {
+		return nil, fmt.Errorf("%s: not an index", indexPath)
+	}
+	r := bytes.NewReader(data[len(indexMagic):])
+
+	ix := &Index{Source: source}
+
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if ix.ModTime, err = binary.ReadVarint(r); err != nil {
+		return nil, err
+	}
+	ix.Size = int64(size)
+
+	if ix.Size != stat.Size() || ix.ModTime != stat.ModTime().UnixNano() {
+		return nil, errStaleIndex
+	}
+
+	if ix.Offsets, err = readDeltas(r); err != nil {
+		return nil, err
+	}
+
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ix.Browsers = make(map[string][]int, count)
+	for ; count > 0; count-- {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		browser := make([]byte, n)
+		r.Read(browser)
+
+		ids, err := readDeltas(r)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := make([]int, len(ids))
+		for i, id := range ids {
+			lines[i] = int(id)
+		}
+		ix.Browsers[string(browser)] = lines
+	}
+
+	return ix, nil
+}
+
+func readDeltas(r *bytes.Reader) ([]int64, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	values := make([]int64, n)
+
+	var prev int64
+	for i := range values {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		prev += int64(delta)
+		values[i] = prev
+	}
+
+	return values, nil
+}
+
+// Search reads only the records that may match q, the report is the same as Search over the whole source
+func (ix *Index) Search(q Query, w ResultWriter) error {
+	cq, err := Compile(q)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(ix.Source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() != ix.Size || stat.ModTime().UnixNano() != ix.ModTime {
+		return errStaleIndex
+	}
+
+	mask := cq.newBrowserMask()
+
+	var candidates []int
+	for browser, lines := range ix.Browsers {
+		if mask.of([]string{browser}) != 0 {
+			candidates = append(candidates, lines...)
+		}
+	}
+
+	if cq.needsBrowser {
+		sort.Ints(candidates)
+	} else {
+		candidates = make([]int, len(ix.Offsets)-1)
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	w = redacting(w)
+
+	var user = userPool.Get().(*User)
+	defer userPool.Put(user)
+
+	var buf []byte
+	for i, line := range candidates {
+		if i > 0 && candidates[i-1] == line {
+			continue
+		}
+
+		start, end := ix.Offsets[line], ix.Offsets[line+1]
+		if cap(buf) < int(end-start) {
+			buf = make([]byte, end-start)
+		}
+		buf = buf[:end-start]
+		if _, err := file.ReadAt(buf, start); err != nil {
+			return err
+		}
+
+		*user = User{Browsers: user.Browsers[:0]}
+		if err := user.UnmarshalProjection(buf, cq.fields); err != nil {
+			return &RecordError{line, err}
+		}
+
+		if cq.match(user, mask.of(user.Browsers)) {
+			w.WriteUser(line, user)
+		}
+	}
+
+	return w.Finish(mask.unique)
+}
+
+// SearchIndexed is Search over source using the index at indexPath, rebuilding it when needed
+func SearchIndexed(source, indexPath string, q Query, w ResultWriter) error {
+	ix, err := OpenIndex(source, indexPath)
+	if err != nil {
+		return err
+	}
+	return ix.Search(q, w)
+}
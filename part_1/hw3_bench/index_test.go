@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSearchIndexed(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "users.txt")
+	indexPath := filepath.Join(dir, "users.idx")
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []Query{
+		And(HasBrowser("Android"), HasBrowser("MSIE")),
+		Or(HasBrowser("Opera Mini"), HasBrowserFamily("Konqueror")),
+		And(Not(HasBrowser("Chrome")), FieldContains("email", ".edu")),
+	}
+
+	check := func() {
+		for _, q := range queries {
+			expected := new(bytes.Buffer)
+			if err := SearchFile(source, q, NewTextWriter(expected), 1); err != nil {
+				t.Fatal(err)
+			}
+
+			got := new(bytes.Buffer)
+			if err := SearchIndexed(source, indexPath, q, NewTextWriter(got)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got.String() != expected.String() {
+				t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", got, expected)
+			}
+		}
+	}
+
+	check()
+	if _, err := os.Stat(indexPath); err != nil {
+		t.Fatalf("index was not saved: %s", err)
+	}
+
+	// appended user must be found through the rebuilt index
+	extra := `{"browsers":["Android 9","MSIE 11"],"email":"new@user.com","name":"New User"}`
+	if err := os.WriteFile(source, append(append(data, '\n'), extra...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	check()
+
+	got := new(bytes.Buffer)
+	if err := SearchIndexed(source, indexPath, HasBrowser("Android 9"), NewTextWriter(got)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got.String(), "New User <new [at] user.com>") {
+		t.Errorf("new user not found\n%v", got)
+	}
+}
+
+func TestIndexRoundTrip(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "users.idx")
+
+	built, err := OpenIndex(filePath, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadIndex(filePath, indexPath, stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.Offsets) != len(built.Offsets) || len(loaded.Browsers) != len(built.Browsers) {
+		t.Fatalf("Got: %d offsets, %d browsers\nExpected: %d offsets, %d browsers",
+			len(loaded.Offsets), len(loaded.Browsers), len(built.Offsets), len(built.Browsers))
+	}
+	for browser, lines := range built.Browsers {
+		if len(loaded.Browsers[browser]) != len(lines) {
+			t.Fatalf("lines of %q not match", browser)
+		}
+	}
+}
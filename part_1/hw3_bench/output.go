@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ResultWriter formats found users, write errors are kept and returned by Finish.
+// Searches redact users before passing them to WriteUser, see Redacted.
+type ResultWriter interface {
+	WriteUser(i int, user *User)
+	Finish(uniqueBrowsers int) error
+}
+
+type textWriter struct {
+	w       *bufio.Writer
+	started bool
+	groups  []BrowserGroup
+}
+
+// NewTextWriter writes the report in the SlowSearch format
+func NewTextWriter(out io.Writer) ResultWriter {
+	return &textWriter{w: bufio.NewWriter(out)}
+}
+
+func (t *textWriter) start() {
+	if !t.started {
+		t.started = true
+		t.w.WriteString("found users:\n")
+	}
+}
+
+func (t *textWriter) WriteUser(i int, user *User) {
+	t.start()
+	t.w.WriteString("[" + strconv.Itoa(i) + "] " + user.Name + " <" + user.Email + ">\n")
+}
+
+func (t *textWriter) WriteBrowserStats(groups []BrowserGroup) {
+	t.groups = groups
+}
+
+func (t *textWriter) Finish(uniqueBrowsers int) error {
+	t.start()
+	t.w.WriteString("\nTotal unique browsers " + strconv.Itoa(uniqueBrowsers) + "\n")
+	if t.groups != nil {
+		t.w.WriteString("\nbrowsers:\n")
+		for _, g := range t.groups {
+			t.w.WriteString(g.Key + ": users " + strconv.Itoa(g.Users) + ", browsers " + strconv.Itoa(g.Browsers) + "\n")
+		}
+	}
+	return t.w.Flush()
+}
+
+type jsonLinesWriter struct {
+	w      *bufio.Writer
+	enc    *json.Encoder
+	err    error
+	groups []BrowserGroup
+}
+
+type jsonLinesUser struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone,omitempty"`
+}
+
+type jsonLinesSummary struct {
+	UniqueBrowsers int            `json:"unique_browsers"`
+	Browsers       []BrowserGroup `json:"browsers,omitempty"`
+}
+
+// NewJSONLinesWriter writes an object per found user followed by a summary object
+func NewJSONLinesWriter(out io.Writer) ResultWriter {
+	w := bufio.NewWriter(out)
+	return &jsonLinesWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+func (j *jsonLinesWriter) WriteUser(i int, user *User) {
+	if j.err == nil {
+		j.err = j.enc.Encode(jsonLinesUser{i, user.Name, user.Email, user.Phone})
+	}
+}
+
+func (j *jsonLinesWriter) WriteBrowserStats(groups []BrowserGroup) {
+	j.groups = groups
+}
+
+func (j *jsonLinesWriter) Finish(uniqueBrowsers int) error {
+	if j.err == nil {
+		j.err = j.enc.Encode(jsonLinesSummary{uniqueBrowsers, j.groups})
+	}
+	if j.err != nil {
+		return j.err
+	}
+	return j.w.Flush()
+}
+
+type csvWriter struct {
+	w       *csv.Writer
+	started bool
+}
+
+// NewCSVWriter writes index,name,email,phone rows, the unique browsers count is not included
+func NewCSVWriter(out io.Writer) ResultWriter {
+	return &csvWriter{w: csv.NewWriter(out)}
+}
+
+func (c *csvWriter) start() {
+	if !c.started {
+		c.started = true
+		c.w.Write([]string{"index", "name", "email", "phone"})
+	}
+}
+
+func (c *csvWriter) WriteUser(i int, user *User) {
+	c.start()
+	c.w.Write([]string{strconv.Itoa(i), user.Name, user.Email, user.Phone})
+}
+
+func (c *csvWriter) Finish(uniqueBrowsers int) error {
+	c.start()
+	c.w.Flush()
+	return c.w.Error()
+}
+
+var (
+	resultWritersMu = &sync.RWMutex{}
+	resultWriters   = map[string]func(io.Writer) ResultWriter{
+		"text":  NewTextWriter,
+		"jsonl": NewJSONLinesWriter,
+		"csv":   NewCSVWriter,
+	}
+)
+
+// RegisterResultWriter adds an output format available through NewResultWriter
+func RegisterResultWriter(format string, newWriter func(io.Writer) ResultWriter) {
+	resultWritersMu.Lock()
+	defer resultWritersMu.Unlock()
+
+	resultWriters[format] = newWriter
+}
+
+func NewResultWriter(format string, out io.Writer) (ResultWriter, bool) {
+	resultWritersMu.RLock()
+	newWriter, ok := resultWriters[format]
+	resultWritersMu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	return newWriter(out), true
+}
+
+func obfuscateEmail(email string) string {
+	var parts = strings.SplitN(email, "@", 2)
+	if len(parts) < 2 {
+		return email
+	}
+	return parts[0] + " [at] " + parts[1]
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSearchGzipInput(t *testing.T) {
+	plainOut := new(bytes.Buffer)
+	if err := Search(strings.NewReader(queryTestUsers), HasBrowser("MSIE"), NewTextWriter(plainOut)); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := new(bytes.Buffer)
+	zw := gzip.NewWriter(compressed)
+	zw.Write([]byte(queryTestUsers))
+	zw.Close()
+
+	gzipOut := new(bytes.Buffer)
+	if err := Search(compressed, HasBrowser("MSIE"), NewTextWriter(gzipOut)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "found users:\n[0] Ann <a [at] x.com>\n[1] Bob <b [at] y.org>\n\nTotal unique browsers 1\n"
+	if plainOut.String() != expected || gzipOut.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\n%v\nExpected:\n%v", plainOut, gzipOut, expected)
+	}
+}
+
+func TestSearchFormats(t *testing.T) {
+	cases := map[string]string{
+		"jsonl": `{"index":0,"name":"Ann","email":"a [at] x.com"}` + "\n" +
+			`{"index":2,"name":"Cid","email":"c [at] x.com"}` + "\n" +
+			`{"unique_browsers":2}` + "\n",
+		"csv": "index,name,email,phone\n0,Ann,a [at] x.com,\n2,Cid,c [at] x.com,\n",
+	}
+
+	for format, expected := range cases {
+		out := new(bytes.Buffer)
+		w, ok := NewResultWriter(format, out)
+		if !ok {
+			t.Fatalf("format %s is not registered", format)
+		}
+
+		if err := Search(strings.NewReader(queryTestUsers), HasBrowser("Android"), w); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != expected {
+			t.Errorf("%s: results not match\nGot:\n%v\nExpected:\n%v", format, out, expected)
+		}
+	}
+}
+
+func TestRegisterResultWriterConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			RegisterResultWriter("test"+strconv.Itoa(i), NewTextWriter)
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, ok := NewResultWriter("text", io.Discard); !ok {
+				t.Error("text format is not registered")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, ok := NewResultWriter("test3", io.Discard); !ok {
+		t.Error("registered format is not found")
+	}
+}
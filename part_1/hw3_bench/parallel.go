@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+)
+
+const defaultChunkSize = 256 << 10
+
+func FastSearchParallel(out io.Writer, workers int) {
+	if err := QuerySearchParallel(out, And(HasBrowser("Android"), HasBrowser("MSIE")), workers); err != nil {
+		panic(err)
+	}
+}
+
+// QuerySearchParallel is QuerySearch splitting the file between workers, 0 means GOMAXPROCS
+func QuerySearchParallel(out io.Writer, q Query, workers int) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return SearchFile(filePath, q, NewTextWriter(out), workers)
+}
+
+type chunk struct {
+	start, end int64
+}
+
+type chunkResult struct {
+	lines    int
+	found    []foundUser
+	browsers map[string]uint64
+	rejects  *Rejects
+	err      error
+}
+
+type foundUser struct {
+	line int
+	user User
+}
+
+// scanParallel parses newline-aligned chunks of r concurrently, found is called in the original order
+func (q *CompiledQuery) scanParallel(r io.ReaderAt, size int64, workers int, chunkSize int64,
+	rejects *Rejects, found func(i int, user *User)) (int, error) {
+	chunks, err := splitChunks(r, size, chunkSize)
+	if err != nil {
+		return 0, err
+	}
+
+	results := make([]chunkResult, len(chunks))
+	idx := make(chan int)
+
+	wg := &sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range idx {
+				results[i] = q.scanChunk(r, chunks[i], rejects != nil)
+			}
+		}()
+	}
+
+	for i := range chunks {
+		idx <- i
+	}
+	close(idx)
+	wg.Wait()
+
+	var base int
+	unique := make(map[string]struct{}, 256)
+
+	for _, res := range results {
+		if res.err != nil {
+			if recErr, ok := res.err.(*RecordError); ok {
+				recErr.Line += base
+			}
+			return 0, res.err
+		}
+
+		if rejects != nil {
+			for _, rec := range res.rejects.Records {
+				rec.Line += base
+				if err := rejects.add(rec); err != nil {
+					return 0, err
+				}
+			}
+		}
+
+		for i := range res.found {
+			found(base+res.found[i].line, &res.found[i].user)
+		}
+		base += res.lines
+
+		for browser, bits := range res.browsers {
+			if bits != 0 {
+				unique[browser] = struct{}{}
+			}
+		}
+	}
+
+	return len(unique), nil
+}
+
+func (q *CompiledQuery) scanChunk(r io.ReaderAt, c chunk, tolerant bool) chunkResult {
+	mask := q.newBrowserMask()
+
+	var rejects *Rejects
+	if tolerant {
+		rejects = &Rejects{}
+	}
+
+	var found []foundUser
+	lines, err := q.scan(io.NewSectionReader(r, c.start, c.end-c.start), mask, rejects, func(i int, user *User) {
+		u := *user
+		u.Browsers = append([]string(nil), user.Browsers...)
+		found = append(found, foundUser{i, u})
+	})
+
+	return chunkResult{lines: lines, found: found, browsers: mask.cache, rejects: rejects, err: err}
+}
+
+// splitChunks cuts [0, size) into pieces of about chunkSize ending right after a newline
+func splitChunks(r io.ReaderAt, size int64, chunkSize int64) ([]chunk, error) {
+	var chunks []chunk
+
+	buf := make([]byte, 4096)
+	for start := int64(0); start < size; {
+		end := start + chunkSize
+		for end < size {
+			n, err := r.ReadAt(buf, end)
+			if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+				end += int64(i) + 1
+				break
+			}
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			end += int64(n)
+			if n == 0 {
+				end = size
+			}
+		}
+		if end > size {
+			end = size
+		}
+
+		chunks = append(chunks, chunk{start, end})
+		start = end
+	}
+
+	return chunks, nil
+}
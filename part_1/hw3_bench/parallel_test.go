@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestParallelSearch(t *testing.T) {
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	for _, workers := range []int{0, 2, 8} {
+		parallelOut := new(bytes.Buffer)
+		FastSearchParallel(parallelOut, workers)
+
+		if fastOut.String() != parallelOut.String() {
+			t.Errorf("workers %d: results not match\nGot:\n%v\nExpected:\n%v", workers, parallelOut, fastOut)
+		}
+	}
+}
+
+func TestParallelSmallChunks(t *testing.T) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cq, err := Compile(And(HasBrowser("Android"), HasBrowser("MSIE")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mask := cq.newBrowserMask()
+	var expected []int
+	if _, err := cq.scan(file, mask, nil, func(i int, user *User) { expected = append(expected, i) }); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int
+	unique, err := cq.scanParallel(file, stat.Size(), 4, 1000, nil, func(i int, user *User) { got = append(got, i) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unique != mask.unique || len(got) != len(expected) {
+		t.Fatalf("Got: %d users, %d browsers\nExpected: %d users, %d browsers", len(got), unique, len(expected), mask.unique)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("results not match\nGot: %v\nExpected: %v", got, expected)
+		}
+	}
+}
+
+func BenchmarkFastParallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FastSearchParallel(ioutil.Discard, 0)
+	}
+}
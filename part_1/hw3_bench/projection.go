@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/mailru/easyjson/jlexer"
+)
+
+// Fields is a set of User fields to decode, the rest are skipped without allocations.
+type Fields uint8
+
+const (
+	FieldBrowsers Fields = 1 << iota
+	FieldCompany
+	FieldCountry
+	FieldEmail
+	FieldJob
+	FieldName
+	FieldPhone
+
+	AllFields = FieldBrowsers | FieldCompany | FieldCountry | FieldEmail | FieldJob | FieldName | FieldPhone
+)
+
+var userFields = map[string]struct {
+	field Fields
+	get   func(u *User) string
+}{
+	"company": {FieldCompany, func(u *User) string { return u.Company }},
+	"country": {FieldCountry, func(u *User) string { return u.Country }},
+	"email":   {FieldEmail, func(u *User) string { return u.Email }},
+	"job":     {FieldJob, func(u *User) string { return u.Job }},
+	"name":    {FieldName, func(u *User) string { return u.Name }},
+	"phone":   {FieldPhone, func(u *User) string { return u.Phone }},
+}
+
+// UnmarshalProjection is UnmarshalJSON decoding only fields
+func (v *User) UnmarshalProjection(data []byte, fields Fields) error {
+	if fields == AllFields {
+		return v.UnmarshalJSON(data)
+	}
+
+	r := jlexer.Lexer{Data: data}
+	decodeUserProjection(&r, v, fields)
+	return r.Error()
+}
+
+// decodeUserProjection is easyjson89aae3efDecodeFast with skipping of not requested fields
+func decodeUserProjection(in *jlexer.Lexer, out *User, fields Fields) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch {
+		case key == "browsers" && fields&FieldBrowsers != 0:
+			in.Delim('[')
+			if out.Browsers == nil {
+				if !in.IsDelim(']') {
+					out.Browsers = make([]string, 0, 4)
+				} else {
+					out.Browsers = []string{}
+				}
+			} else {
+				out.Browsers = (out.Browsers)[:0]
+			}
+			for !in.IsDelim(']') {
+				out.Browsers = append(out.Browsers, string(in.String()))
+				in.WantComma()
+			}
+			in.Delim(']')
+		case key == "company" && fields&FieldCompany != 0:
+			out.Company = string(in.String())
+		case key == "country" && fields&FieldCountry != 0:
+			out.Country = string(in.String())
+		case key == "email" && fields&FieldEmail != 0:
+			out.Email = string(in.String())
+		case key == "job" && fields&FieldJob != 0:
+			out.Job = string(in.String())
+		case key == "name" && fields&FieldName != 0:
+			out.Name = string(in.String())
+		case key == "phone" && fields&FieldPhone != 0:
+			out.Phone = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+const projectionTestUser = `{"browsers":["Opera"],"company":"Flashpoint","country":"Kenya","email":"a@x.com","job":"Programmer","name":"Ann","phone":"176-88-49","extra":{"a":[1,2]}}`
+
+func TestUnmarshalProjection(t *testing.T) {
+	full := User{}
+	if err := full.UnmarshalJSON([]byte(projectionTestUser)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := User{
+		Browsers: []string{"Opera"},
+		Company:  "Flashpoint",
+		Country:  "Kenya",
+		Email:    "a@x.com",
+		Job:      "Programmer",
+		Name:     "Ann",
+		Phone:    "176-88-49",
+	}
+	if full.Company != expected.Company || full.Phone != expected.Phone || full.Job != expected.Job ||
+		full.Country != expected.Country || len(full.Browsers) != 1 {
+		t.Errorf("full decode\nGot: %+v\nExpected: %+v", full, expected)
+	}
+
+	projected := User{}
+	if err := projected.UnmarshalProjection([]byte(projectionTestUser), FieldName|FieldCountry); err != nil {
+		t.Fatal(err)
+	}
+	if projected.Name != "Ann" || projected.Country != "Kenya" ||
+		projected.Email != "" || projected.Company != "" || projected.Browsers != nil {
+		t.Errorf("projection decode\nGot: %+v", projected)
+	}
+
+	if err := projected.UnmarshalProjection([]byte(`{"name":`), FieldName); err == nil {
+		t.Error("expected error for broken json")
+	}
+}
+
+func TestQueryExtraFields(t *testing.T) {
+	names, _ := queryNamesIn(t, FieldContains("country", "Kenya"), projectionTestUser)
+	if len(names) != 1 || names[0] != "Ann" {
+		t.Errorf("Got: %v, Expected: [Ann]", names)
+	}
+
+	names, _ = queryNamesIn(t, FieldContains("company", "Jatri"), projectionTestUser)
+	if len(names) != 0 {
+		t.Errorf("Got: %v, Expected none", names)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Query selects users, it is compiled once into a matcher used by the streaming scan.
+type Query interface {
+	compile(c *queryCompiler) (userMatcher, error)
+	// needsBrowser reports that only users with a browser matched by some browser predicate can match
+	needsBrowser() bool
+}
+
+// browsers has a bit set for every browser predicate matched by any of the user browsers
+type userMatcher func(u *User, browsers uint64) bool
+
+type queryCompiler struct {
+	browserPreds []func(string) bool
+	fields       Fields
+}
+
+const maxBrowserPredicates = 64
+
+func (c *queryCompiler) addBrowserPredicate(pred func(string) bool) (uint64, error) {
+	if len(c.browserPreds) == maxBrowserPredicates {
+		return 0, fmt.Errorf("too many browser predicates, max %d", maxBrowserPredicates)
+	}
+
+	c.browserPreds = append(c.browserPreds, pred)
+	return 1 << uint(len(c.browserPreds)-1), nil
+}
+
+type browserQuery struct {
+	pred func(string) bool
+}
+
+func (q browserQuery) compile(c *queryCompiler) (userMatcher, error) {
+	bit, err := c.addBrowserPredicate(q.pred)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(u *User, browsers uint64) bool {
+		return browsers&bit != 0
+	}, nil
+}
+
+func (q browserQuery) needsBrowser() bool {
+	return true
+}
+
+// HasBrowser matches users with a browser containing substr
+func HasBrowser(substr string) Query {
+	return browserQuery{func(browser string) bool {
+		return strings.Contains(browser, substr)
+	}}
+}
+
+func BrowserMatches(re *regexp.Regexp) Query {
+	return browserQuery{re.MatchString}
+}
+
+type fieldQuery struct {
+	field string
+	pred  func(string) bool
+}
+
+func (q fieldQuery) compile(c *queryCompiler) (userMatcher, error) {
+	f, ok := userFields[q.field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", q.field)
+	}
+	c.fields |= f.field
+
+	return func(u *User, browsers uint64) bool {
+		return q.pred(f.get(u))
+	}, nil
+}
+
+func (q fieldQuery) needsBrowser() bool {
+	return false
+}
+
+func FieldContains(field, substr string) Query {
+	return fieldQuery{field, func(value string) bool {
+		return strings.Contains(value, substr)
+	}}
+}
+
+func FieldMatches(field string, re *regexp.Regexp) Query {
+	return fieldQuery{field, re.MatchString}
+}
+
+type andQuery []Query
+
+func And(queries ...Query) Query {
+	return andQuery(queries)
+}
+
+func (q andQuery) compile(c *queryCompiler) (userMatcher, error) {
+	matchers, err := compileAll(c, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(u *User, browsers uint64) bool {
+		for _, m := range matchers {
+			if !m(u, browsers) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func (q andQuery) needsBrowser() bool {
+	for _, sub := range q {
+		if sub.needsBrowser() {
+			return true
+		}
+	}
+	return false
+}
+
+type orQuery []Query
+
+func Or(queries ...Query) Query {
+	return orQuery(queries)
+}
+
+func (q orQuery) compile(c *queryCompiler) (userMatcher, error) {
+	matchers, err := compileAll(c, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(u *User, browsers uint64) bool {
+		for _, m := range matchers {
+			if m(u, browsers) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (q orQuery) needsBrowser() bool {
+	for _, sub := range q {
+		if !sub.needsBrowser() {
+			return false
+		}
+	}
+	return len(q) > 0
+}
+
+type notQuery struct {
+	query Query
+}
+
+func Not(q Query) Query {
+	return notQuery{q}
+}
+
+func (q notQuery) compile(c *queryCompiler) (userMatcher, error) {
+	m, err := q.query.compile(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(u *User, browsers uint64) bool {
+		return !m(u, browsers)
+	}, nil
+}
+
+func (q notQuery) needsBrowser() bool {
+	return false
+}
+
+func compileAll(c *queryCompiler, queries []Query) ([]userMatcher, error) {
+	matchers := make([]userMatcher, 0, len(queries))
+	for _, q := range queries {
+		m, err := q.compile(c)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+type CompiledQuery struct {
+	match        userMatcher
+	browserPreds []func(string) bool
+	needsBrowser bool
+	// fields are decoded for every record: browsers, the report fields and the ones used by the query
+	fields Fields
+}
+
+func Compile(q Query) (*CompiledQuery, error) {
+	c := &queryCompiler{}
+
+	m, err := q.compile(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompiledQuery{
+		match:        m,
+		browserPreds: c.browserPreds,
+		needsBrowser: q.needsBrowser(),
+		fields:       FieldBrowsers | FieldName | FieldEmail | FieldPhone | c.fields,
+	}, nil
+}
+
+// browserMask evaluates browser predicates only once per distinct browser string
+type browserMask struct {
+	preds  []func(string) bool
+	cache  map[string]uint64
+	unique int
+}
+
+func (q *CompiledQuery) newBrowserMask() *browserMask {
+	return &browserMask{preds: q.browserPreds, cache: make(map[string]uint64, 256)}
+}
+
+func (b *browserMask) of(browsers []string) uint64 {
+	var mask uint64
+
+	for _, browser := range browsers {
+		bits, found := b.cache[browser]
+		if !found {
+			for i, pred := range b.preds {
+				if pred(browser) {
+					bits |= 1 << uint(i)
+				}
+			}
+			b.cache[browser] = bits
+
+			if bits != 0 {
+				b.unique++
+			}
+		}
+		mask |= bits
+	}
+
+	return mask
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const queryTestUsers = `{"browsers":["Android 4","MSIE 8"],"email":"a@x.com","name":"Ann"}
+{"browsers":["Chrome/41","MSIE 8"],"email":"b@y.org","name":"Bob"}
+{"browsers":["Android 5"],"email":"c@x.com","name":"Cid"}
+{"browsers":["Opera"],"email":"d@z.net","name":"Dan"}`
+
+func queryNames(t *testing.T, q Query) ([]string, int) {
+	return queryNamesIn(t, q, queryTestUsers)
+}
+
+func queryNamesIn(t *testing.T, q Query, users string) ([]string, int) {
+	cq, err := Compile(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	mask := cq.newBrowserMask()
+	_, err = cq.scan(strings.NewReader(users), mask, nil, func(i int, user *User) {
+		names = append(names, user.Name)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return names, mask.unique
+}
+
+func TestQuery(t *testing.T) {
+	cases := []struct {
+		query  Query
+		names  string
+		unique int
+	}{
+		{And(HasBrowser("Android"), HasBrowser("MSIE")), "Ann", 3},
+		{Or(HasBrowser("Android"), HasBrowser("Opera")), "Ann,Cid,Dan", 3},
+		{Not(HasBrowser("MSIE")), "Cid,Dan", 1},
+		{BrowserMatches(regexp.MustCompile(`^Android [45]$`)), "Ann,Cid", 2},
+		{FieldContains("email", "@x.com"), "Ann,Cid", 0},
+		{And(FieldMatches("name", regexp.MustCompile("^[AB]")), Not(HasBrowser("Android"))), "Bob", 2},
+	}
+
+	for _, c := range cases {
+		names, unique := queryNames(t, c.query)
+		if got := strings.Join(names, ","); got != c.names || unique != c.unique {
+			t.Errorf("query %#v\nGot: %s, %d\nExpected: %s, %d", c.query, got, unique, c.names, c.unique)
+		}
+	}
+}
+
+func TestQueryUnknownField(t *testing.T) {
+	if _, err := Compile(FieldContains("salary", "1")); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
+
+func TestQuerySearchMatchesFastSearch(t *testing.T) {
+	fastOut := new(bytes.Buffer)
+	FastSearch(fastOut)
+
+	queryOut := new(bytes.Buffer)
+	if err := QuerySearch(queryOut, And(HasBrowser("MSIE"), HasBrowser("Android"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if fastOut.String() != queryOut.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", queryOut, fastOut)
+	}
+}
+
+func BenchmarkQuerySearch(b *testing.B) {
+	q := Or(And(HasBrowser("Android"), HasBrowser("MSIE")), FieldContains("email", ".edu"))
+	for i := 0; i < b.N; i++ {
+		QuerySearch(ioutil.Discard, q)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+type RejectedRecord struct {
+	Line   int
+	Reason string
+	Raw    string
+}
+
+// Rejects enables tolerant search: malformed records are skipped and collected instead of failing.
+type Rejects struct {
+	// Quarantine receives every rejected line as is
+	Quarantine io.Writer
+	Records    []RejectedRecord
+}
+
+func (r *Rejects) add(rec RejectedRecord) error {
+	r.Records = append(r.Records, rec)
+
+	if r.Quarantine == nil {
+		return nil
+	}
+
+	_, err := io.WriteString(r.Quarantine, rec.Raw+"\n")
+	return err
+}
+
+func (r *Rejects) WriteSummary(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	w.WriteString("Rejected records " + strconv.Itoa(len(r.Records)) + "\n")
+	for _, rec := range r.Records {
+		w.WriteString("[" + strconv.Itoa(rec.Line) + "] " + rec.Reason + "\n")
+	}
+
+	return w.Flush()
+}
+
+func validEmail(email string) bool {
+	at := strings.IndexByte(email, '@')
+	if at <= 0 || at == len(email)-1 || strings.IndexByte(email[at+1:], '@') >= 0 {
+		return false
+	}
+	return !strings.ContainsAny(email, " \t\r\n<>")
+}
+
+// readLine returns the next line including '\n', it is valid until the next call.
+// Unlike bufio.Reader.ReadSlice lines longer than the reader buffer are supported.
+func readLine(reader *bufio.Reader, long *[]byte) ([]byte, error) {
+	line, err := reader.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+
+	*long = append((*long)[:0], line...)
+	for err == bufio.ErrBufferFull {
+		line, err = reader.ReadSlice('\n')
+		*long = append(*long, line...)
+	}
+
+	return *long, err
+}
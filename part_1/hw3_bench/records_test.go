@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var malformedUsers = strings.Join([]string{
+	`{"browsers":["Android 4","MSIE 8"],"email":"a@x.com","name":"Ann"}`,
+	`{"browsers":["Android 4","MSIE 8"],"email":"broken","name":"Bob"}`,
+	`{"browsers":["Android 4",`,
+	`{"browsers":["Android 5","MSIE 9"],"email":"c@x.com","name":"` + strings.Repeat("C", 5000) + `"}`,
+	``,
+	`{"browsers":["Android 6","MSIE 10"],"email":"d@@x.com","name":"Dan"}`,
+	`{"browsers":["Android 7","MSIE 11"],"email":"e@x.com","name":"Eve"}`,
+}, "\n")
+
+func TestSearchTolerant(t *testing.T) {
+	quarantine := new(bytes.Buffer)
+	rejects := &Rejects{Quarantine: quarantine}
+
+	out := new(bytes.Buffer)
+	err := SearchTolerant(strings.NewReader(malformedUsers), HasBrowser("MSIE"), NewJSONLinesWriter(out), rejects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], `{"index":3,`) || lines[3] != `{"unique_browsers":3}` {
+		t.Errorf("unexpected report\n%s", out)
+	}
+
+	var rejected []int
+	for _, rec := range rejects.Records {
+		rejected = append(rejected, rec.Line)
+	}
+	if len(rejected) != 4 || rejected[0] != 1 || rejected[1] != 2 || rejected[2] != 4 || rejected[3] != 5 {
+		t.Errorf("Got rejected lines: %v\nExpected: [1 2 4 5]", rejected)
+	}
+
+	expectedQuarantine := strings.Join([]string{
+		`{"browsers":["Android 4","MSIE 8"],"email":"broken","name":"Bob"}`,
+		`{"browsers":["Android 4",`,
+		``,
+		`{"browsers":["Android 6","MSIE 10"],"email":"d@@x.com","name":"Dan"}`,
+	}, "\n") + "\n"
+	if quarantine.String() != expectedQuarantine {
+		t.Errorf("quarantine not match\nGot:\n%s\nExpected:\n%s", quarantine, expectedQuarantine)
+	}
+
+	summary := new(bytes.Buffer)
+	rejects.WriteSummary(summary)
+	if !strings.HasPrefix(summary.String(), "Rejected records 4\n[1] invalid email \"broken\"\n[2] ") {
+		t.Errorf("unexpected summary\n%s", summary)
+	}
+}
+
+func TestSearchStrictLineNumber(t *testing.T) {
+	err := Search(strings.NewReader(malformedUsers), HasBrowser("MSIE"), NewTextWriter(new(bytes.Buffer)))
+
+	var recErr *RecordError
+	if !errors.As(err, &recErr) || recErr.Line != 2 {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+}
+
+func TestSearchTolerantParallel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.txt")
+	data := strings.Repeat(malformedUsers+"\n", 50)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRejects := &Rejects{}
+	expected := new(bytes.Buffer)
+	if err := SearchFileTolerant(path, HasBrowser("MSIE"), NewTextWriter(expected), 1, expectedRejects); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	cq, err := Compile(HasBrowser("MSIE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejects := &Rejects{}
+	got := NewTextWriter(new(bytes.Buffer))
+	if _, err := cq.scanParallel(file, int64(len(data)), 4, 2000, rejects, got.WriteUser); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rejects.Records) != len(expectedRejects.Records) {
+		t.Fatalf("Got %d rejects, Expected %d", len(rejects.Records), len(expectedRejects.Records))
+	}
+	for i := range rejects.Records {
+		if rejects.Records[i].Line != expectedRejects.Records[i].Line {
+			t.Fatalf("reject %d\nGot: line %d\nExpected: line %d", i, rejects.Records[i].Line, expectedRejects.Records[i].Line)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Redaction rewrites a personal value before it gets into a report.
+type Redaction func(value string) string
+
+var (
+	RedactKeep Redaction = func(value string) string { return value }
+	// RedactObfuscate writes emails as "local [at] domain"
+	RedactObfuscate Redaction = obfuscateEmail
+	RedactHash      Redaction = RedactHashSalted("")
+	// RedactMaskDomain keeps the local part of emails only, values without '@' are masked completely
+	RedactMaskDomain Redaction = maskDomain
+	RedactDrop       Redaction = func(string) string { return "" }
+)
+
+// RedactHashSalted replaces values with a short sha256 hash, the same values get the same hash.
+// Without a secret salt hashes of known emails or phones can be looked up.
+func RedactHashSalted(salt string) Redaction {
+	return func(value string) string {
+		if value == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(salt + value))
+		return hex.EncodeToString(sum[:6])
+	}
+}
+
+var redactions = map[string]Redaction{
+	"keep":        RedactKeep,
+	"obfuscate":   RedactObfuscate,
+	"hash":        RedactHash,
+	"mask-domain": RedactMaskDomain,
+	"drop":        RedactDrop,
+}
+
+func maskDomain(value string) string {
+	at := strings.LastIndexByte(value, '@')
+	if at < 0 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:at] + "@***"
+}
+
+// RedactionPolicy sets a redaction per field of found users, nil keeps the value.
+type RedactionPolicy struct {
+	Name  Redaction
+	Email Redaction
+	Phone Redaction
+}
+
+// DefaultRedaction is applied by searches to writers without Redacted, phones are written only when asked for
+var DefaultRedaction = RedactionPolicy{Email: RedactObfuscate, Phone: RedactDrop}
+
+// ParseRedactionPolicy parses "field=redaction" pairs separated by commas, e.g. "email=hash,phone=drop".
+// Fields not mentioned get DefaultRedaction.
+func ParseRedactionPolicy(spec string) (RedactionPolicy, error) {
+	p := DefaultRedaction
+	if spec == "" {
+		return p, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		field, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return p, fmt.Errorf("bad redaction %q, expected field=redaction", pair)
+		}
+
+		r, ok := redactions[name]
+		if !ok {
+			return p, fmt.Errorf("unknown redaction %q", name)
+		}
+
+		switch field {
+		case "name":
+			p.Name = r
+		case "email":
+			p.Email = r
+		case "phone":
+			p.Phone = r
+		default:
+			return p, fmt.Errorf("field %q can not be redacted", field)
+		}
+	}
+
+	return p, nil
+}
+
+// Apply returns a redacted copy of user, or user itself when the policy redacts nothing.
+func (p RedactionPolicy) Apply(user *User) *User {
+	if p.Name == nil && p.Email == nil && p.Phone == nil {
+		return user
+	}
+
+	redacted := *user
+	if p.Name != nil {
+		redacted.Name = p.Name(user.Name)
+	}
+	if p.Email != nil {
+		redacted.Email = p.Email(user.Email)
+	}
+	if p.Phone != nil {
+		redacted.Phone = p.Phone(user.Phone)
+	}
+	return &redacted
+}
+
+type redactedWriter struct {
+	w      ResultWriter
+	policy RedactionPolicy
+}
+
+// Redacted sets the policy the search applies to found users before writing them to w,
+// searches without it apply DefaultRedaction. The outermost Redacted of a writer chain wins,
+// users are redacted once however writers are nested.
+func Redacted(w ResultWriter, policy RedactionPolicy) ResultWriter {
+	return &redactedWriter{w, policy}
+}
+
+func (r *redactedWriter) WriteUser(i int, user *User) {
+	r.w.WriteUser(i, user)
+}
+
+func (r *redactedWriter) Finish(uniqueBrowsers int) error {
+	return r.w.Finish(uniqueBrowsers)
+}
+
+func (r *redactedWriter) WriteBrowserStats(groups []BrowserGroup) {
+	if s, ok := r.w.(BrowserStatsWriter); ok {
+		s.WriteBrowserStats(groups)
+	}
+}
+
+func (r *redactedWriter) Unwrap() ResultWriter {
+	return r.w
+}
+
+// redactionOf finds the policy set by Redacted in the chain of wrappers ending with w
+func redactionOf(w ResultWriter) RedactionPolicy {
+	for {
+		if r, ok := w.(*redactedWriter); ok {
+			return r.policy
+		}
+		u, ok := w.(interface{ Unwrap() ResultWriter })
+		if !ok {
+			return DefaultRedaction
+		}
+		w = u.Unwrap()
+	}
+}
+
+type redactingWriter struct {
+	ResultWriter
+	policy RedactionPolicy
+}
+
+// redacting is called by every search before the first user is written
+func redacting(w ResultWriter) ResultWriter {
+	return &redactingWriter{w, redactionOf(w)}
+}
+
+func (r *redactingWriter) WriteUser(i int, user *User) {
+	r.ResultWriter.WriteUser(i, r.policy.Apply(user))
+}
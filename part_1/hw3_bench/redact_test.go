@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRedactions(t *testing.T) {
+	cases := []struct {
+		name     string
+		r        Redaction
+		value    string
+		expected string
+	}{
+		{"keep", RedactKeep, "a@x.com", "a@x.com"},
+		{"obfuscate", RedactObfuscate, "a@x.com", "a [at] x.com"},
+		{"obfuscate no at", RedactObfuscate, "broken", "broken"},
+		{"mask domain", RedactMaskDomain, "a@x.com", "a@***"},
+		{"mask no at", RedactMaskDomain, "555-12", "******"},
+		{"drop", RedactDrop, "Ann", ""},
+		{"hash empty", RedactHash, "", ""},
+	}
+
+	for _, c := range cases {
+		if got := c.r(c.value); got != c.expected {
+			t.Errorf("%s: got %q, expected %q", c.name, got, c.expected)
+		}
+	}
+
+	if h := RedactHash("a@x.com"); len(h) != 12 || h != RedactHash("a@x.com") || h == RedactHashSalted("s")("a@x.com") {
+		t.Errorf("bad hash %q", h)
+	}
+}
+
+func TestParseRedactionPolicy(t *testing.T) {
+	p, err := ParseRedactionPolicy("name=drop, phone=mask-domain")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := p.Apply(&User{Name: "Ann", Email: "a@x.com", Phone: "555"})
+	if user.Name != "" || user.Email != "a [at] x.com" || user.Phone != "***" {
+		t.Errorf("bad redaction %+v", user)
+	}
+
+	user = &User{Name: "Ann"}
+	if (RedactionPolicy{}).Apply(user) != user {
+		t.Error("empty policy copied the user")
+	}
+
+	for _, spec := range []string{"name", "name=rot13", "browsers=drop"} {
+		if _, err := ParseRedactionPolicy(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestRedactedWriter(t *testing.T) {
+	out := new(bytes.Buffer)
+	w := Redacted(NewTextWriter(out), RedactionPolicy{Name: RedactHash, Email: RedactMaskDomain})
+
+	if err := Search(strings.NewReader(queryTestUsers), HasBrowser("MSIE"), w); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "found users:\n[0] " + RedactHash("Ann") + " <a@***>\n[1] " + RedactHash("Bob") + " <b@***>\n\nTotal unique browsers 1\n"
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out, expected)
+	}
+}
+
+func TestRedactedPhone(t *testing.T) {
+	input := `{"browsers":["MSIE 8"],"email":"a@x.com","name":"Ann","phone":"555-12-34"}`
+	cases := []struct {
+		format, spec, expected string
+	}{
+		{"csv", "", "index,name,email,phone\n0,Ann,a [at] x.com,\n"},
+		{"csv", "phone=keep", "index,name,email,phone\n0,Ann,a [at] x.com,555-12-34\n"},
+		{"jsonl", "", `{"index":0,"name":"Ann","email":"a [at] x.com"}` + "\n" + `{"unique_browsers":1}` + "\n"},
+		{"jsonl", "phone=mask-domain", `{"index":0,"name":"Ann","email":"a [at] x.com","phone":"*********"}` + "\n" + `{"unique_browsers":1}` + "\n"},
+	}
+
+	for _, c := range cases {
+		policy, err := ParseRedactionPolicy(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out := new(bytes.Buffer)
+		w, _ := NewResultWriter(c.format, out)
+		if err := Search(strings.NewReader(input), HasBrowser("MSIE"), Redacted(w, policy)); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != c.expected {
+			t.Errorf("%s %q: results not match\nGot:\n%v\nExpected:\n%v", c.format, c.spec, out, c.expected)
+		}
+	}
+}
+
+type capturedUsers []User
+
+func (c *capturedUsers) WriteUser(i int, user *User)     { *c = append(*c, *user) }
+func (c *capturedUsers) Finish(uniqueBrowsers int) error { return nil }
+
+func TestRedactedOnce(t *testing.T) {
+	input := `{"browsers":["MSIE 8"],"email":"a@x.com","name":"Ann","phone":"555"}`
+	policy, err := ParseRedactionPolicy("email=mask-domain,phone=keep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	family := func(ua UserAgent) string { return ua.Family }
+
+	nested := map[string]func(w ResultWriter) ResultWriter{
+		"redacted outside": func(w ResultWriter) ResultWriter { return Redacted(GroupedByBrowser(w, family), policy) },
+		"redacted inside":  func(w ResultWriter) ResultWriter { return GroupedByBrowser(Redacted(w, policy), family) },
+	}
+	for name, wrap := range nested {
+		out := new(bytes.Buffer)
+		if err := Search(strings.NewReader(input), HasBrowser("MSIE"), wrap(NewCSVWriter(out))); err != nil {
+			t.Fatal(err)
+		}
+		if expected := "index,name,email,phone\n0,Ann,a@***,555\n"; out.String() != expected {
+			t.Errorf("%s: results not match\nGot:\n%v\nExpected:\n%v", name, out, expected)
+		}
+	}
+
+	// writers added with RegisterResultWriter get users redacted by the search too
+	users := &capturedUsers{}
+	if err := Search(strings.NewReader(input), HasBrowser("MSIE"), users); err != nil {
+		t.Fatal(err)
+	}
+	if len(*users) != 1 || (*users)[0].Email != "a [at] x.com" || (*users)[0].Phone != "" {
+		t.Errorf("default redaction is not applied: %+v", *users)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	DeviceDesktop      = "desktop"
+	DeviceMobile       = "mobile"
+	DeviceTablet       = "tablet"
+	DeviceFeaturePhone = "feature phone"
+	DeviceBot          = "bot"
+)
+
+type UserAgent struct {
+	Family string
+	// Version is the major version, empty if unknown
+	Version string
+	OS      string
+	Device  string
+}
+
+// String formats the agent as "Chrome 41 on Linux"
+func (ua UserAgent) String() string {
+	name := ua.Family
+	if ua.Version != "" {
+		name += " " + ua.Version
+	}
+	return name + " on " + ua.OS
+}
+
+type uaFamilyRule struct {
+	token  string
+	family string
+	// version is read after this token instead of token when set
+	versionToken string
+}
+
+// order matters: many agents mention Chrome, Safari or Mozilla besides the real browser
+var uaFamilyRules = []uaFamilyRule{
+	{"Edge/", "Edge", ""},
+	{"OPR/", "Opera", ""},
+	{"Opera Mini/", "Opera Mini", ""},
+	{"Opera", "Opera", "Version/"},
+	{"SeaMonkey/", "SeaMonkey", ""},
+	{"Firefox/", "Firefox", ""},
+	{"FxiOS/", "Firefox", ""},
+	{"CriOS/", "Chrome", ""},
+	{"Chrome/", "Chrome", ""},
+	{"Konqueror/", "Konqueror", ""},
+	{"MSIE ", "IE", ""},
+	{"Trident/", "IE", "rv:"},
+	{"Android", "Android Browser", "Version/"},
+	{"Safari", "Safari", "Version/"},
+}
+
+var uaOSRules = []struct {
+	token string
+	os    string
+}{
+	{"Windows Phone", "Windows Phone"},
+	{"Windows", "Windows"},
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"iPod", "iOS"},
+	{"Mac OS X", "Mac OS X"},
+	{"Macintosh", "Mac OS X"},
+	{"CrOS", "Chrome OS"},
+	{"Symbian", "Symbian"},
+	{"Series60", "Symbian"},
+	{"FreeBSD", "FreeBSD"},
+	{"OpenBSD", "OpenBSD"},
+	{"SunOS", "SunOS"},
+	{"Linux", "Linux"},
+	{"X11", "Linux"},
+	{"MIDP", "J2ME"},
+}
+
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.2":  "XP",
+	"5.1":  "XP",
+	"5.0":  "2000",
+}
+
+func ParseUserAgent(s string) UserAgent {
+	ua := UserAgent{Family: "Other", OS: "Other", Device: DeviceDesktop}
+
+	bot := botName(s)
+	isBot := bot != ""
+
+	for _, rule := range uaFamilyRules {
+		if !strings.Contains(s, rule.token) {
+			continue
+		}
+		ua.Family = rule.family
+		if rule.versionToken != "" {
+			ua.Version = majorVersion(s, rule.versionToken)
+		}
+		if ua.Version == "" {
+			ua.Version = majorVersion(s, rule.token)
+		}
+		break
+	}
+	if isBot {
+		ua.Family, ua.Version = bot, ""
+	}
+
+	for _, rule := range uaOSRules {
+		if strings.Contains(s, rule.token) {
+			ua.OS = rule.os
+			break
+		}
+	}
+	if ua.OS == "Windows" {
+		if v, ok := windowsVersions[versionAfter(s, "Windows NT ")]; ok {
+			ua.OS += " " + v
+		}
+	}
+
+	switch {
+	case isBot:
+		ua.Device = DeviceBot
+	case strings.Contains(s, "iPad") || strings.Contains(s, "Tablet") ||
+		ua.OS == "Android" && !strings.Contains(s, "Mobile"):
+		ua.Device = DeviceTablet
+	case strings.Contains(s, "MIDP") && ua.OS == "J2ME":
+		ua.Device = DeviceFeaturePhone
+	case strings.Contains(s, "Mobile") || strings.Contains(s, "iPhone") || strings.Contains(s, "iPod") ||
+		ua.OS == "Windows Phone" || ua.OS == "Symbian" || ua.OS == "Android":
+		ua.Device = DeviceMobile
+	}
+
+	return ua
+}
+
+// versionAfter returns digits and dots following token and an optional separator
+func versionAfter(s, token string) string {
+	idx := strings.Index(s, token)
+	if idx < 0 {
+		return ""
+	}
+
+	s = strings.TrimLeft(s[idx+len(token):], "/ ")
+	end := 0
+	for end < len(s) && (s[end] >= '0' && s[end] <= '9' || s[end] == '.') {
+		end++
+	}
+	return strings.TrimRight(s[:end], ".")
+}
+
+func majorVersion(s, token string) string {
+	v := versionAfter(s, token)
+	if dot := strings.IndexByte(v, '.'); dot >= 0 {
+		v = v[:dot]
+	}
+	return v
+}
+
+// botTokens are compared with whole words of the agent, so "CUBOT" phones or "Abbot" are not bots
+var botTokens = map[string]bool{
+	"bot":     true,
+	"robot":   true,
+	"crawler": true,
+	"spider":  true,
+	// known crawlers
+	"googlebot":           true,
+	"bingbot":             true,
+	"yandexbot":           true,
+	"baiduspider":         true,
+	"duckduckbot":         true,
+	"applebot":            true,
+	"slurp":               true,
+	"ahrefsbot":           true,
+	"semrushbot":          true,
+	"mj12bot":             true,
+	"petalbot":            true,
+	"twitterbot":          true,
+	"facebookexternalhit": true,
+}
+
+// botName returns the word naming the crawler, or "" for other agents
+func botName(s string) string {
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if botTokens[strings.ToLower(word)] {
+			return word
+		}
+	}
+	return ""
+}
+
+func browserUAQuery(match func(ua UserAgent) bool) Query {
+	return browserQuery{func(browser string) bool {
+		return match(ParseUserAgent(browser))
+	}}
+}
+
+func HasBrowserFamily(family string) Query {
+	return browserUAQuery(func(ua UserAgent) bool { return ua.Family == family })
+}
+
+func HasBrowserOS(os string) Query {
+	return browserUAQuery(func(ua UserAgent) bool { return ua.OS == os || strings.HasPrefix(ua.OS, os+" ") })
+}
+
+func HasBrowserDevice(device string) Query {
+	return browserUAQuery(func(ua UserAgent) bool { return ua.Device == device })
+}
+
+type BrowserGroup struct {
+	Key string `json:"key"`
+	// Users is the number of matched users with at least one browser in the group
+	Users int `json:"users"`
+	// Browsers is the number of distinct user-agent strings in the group
+	Browsers int `json:"browsers"`
+}
+
+// browserGrouper counts users by key of their classified browsers, every browser string is parsed once
+type browserGrouper struct {
+	key      func(ua UserAgent) string
+	keys     map[string]string
+	groups   map[string]*BrowserGroup
+	userKeys map[string]struct{}
+}
+
+func newBrowserGrouper(key func(ua UserAgent) string) *browserGrouper {
+	return &browserGrouper{
+		key:      key,
+		keys:     make(map[string]string, 256),
+		groups:   make(map[string]*BrowserGroup),
+		userKeys: make(map[string]struct{}, 4),
+	}
+}
+
+func (g *browserGrouper) add(browsers []string) {
+	for k := range g.userKeys {
+		delete(g.userKeys, k)
+	}
+
+	for _, browser := range browsers {
+		k, ok := g.keys[browser]
+		if !ok {
+			k = g.key(ParseUserAgent(browser))
+			g.keys[browser] = k
+			if g.groups[k] == nil {
+				g.groups[k] = &BrowserGroup{Key: k}
+			}
+			g.groups[k].Browsers++
+		}
+		g.userKeys[k] = struct{}{}
+	}
+
+	for k := range g.userKeys {
+		g.groups[k].Users++
+	}
+}
+
+// result returns groups with users, largest first
+func (g *browserGrouper) result() []BrowserGroup {
+	result := make([]BrowserGroup, 0, len(g.groups))
+	for _, group := range g.groups {
+		if group.Users > 0 {
+			result = append(result, *group)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Users != result[j].Users {
+			return result[i].Users > result[j].Users
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// GroupUsers counts users matching q by key of their classified browsers, largest groups first
+func GroupUsers(r io.Reader, q Query, key func(ua UserAgent) string) ([]BrowserGroup, error) {
+	cq, err := Compile(q)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err = decompress(r)
+	if err != nil {
+		return nil, err
+	}
+
+	grouper := newBrowserGrouper(key)
+	_, err = cq.scan(r, cq.newBrowserMask(), nil, func(i int, user *User) {
+		grouper.add(user.Browsers)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grouper.result(), nil
+}
+
+// BrowserStatsWriter is implemented by writers that can report found users grouped by browser
+type BrowserStatsWriter interface {
+	WriteBrowserStats(groups []BrowserGroup)
+}
+
+type groupedWriter struct {
+	w       ResultWriter
+	grouper *browserGrouper
+}
+
+// GroupedByBrowser adds found users grouped by key of their classified browsers to the report of w.
+// Writers without BrowserStatsWriter, like csv, write the report as is.
+func GroupedByBrowser(w ResultWriter, key func(ua UserAgent) string) ResultWriter {
+	return &groupedWriter{w, newBrowserGrouper(key)}
+}
+
+func (g *groupedWriter) WriteUser(i int, user *User) {
+	g.grouper.add(user.Browsers)
+	g.w.WriteUser(i, user)
+}
+
+func (g *groupedWriter) Unwrap() ResultWriter {
+	return g.w
+}
+
+func (g *groupedWriter) Finish(uniqueBrowsers int) error {
+	if s, ok := g.w.(BrowserStatsWriter); ok {
+		s.WriteBrowserStats(g.grouper.result())
+	}
+	return g.w.Finish(uniqueBrowsers)
+}
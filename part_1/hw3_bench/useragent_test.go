@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	cases := map[string]UserAgent{
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2227.0 Safari/537.36": {
+			"Chrome", "41", "Linux", DeviceDesktop},
+		"Mozilla/4.0 (compatible; MSIE 7.0; Windows NT 6.0; Trident/5.0)": {
+			"IE", "7", "Windows Vista", DeviceDesktop},
+		"Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; MATBJS; rv:11.0) like Gecko": {
+			"IE", "11", "Windows 10", DeviceDesktop},
+		"Mozilla/5.0 (Windows NT 6.2; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2272.76 Safari/537.36 OPR/28.0.1750.40": {
+			"Opera", "28", "Windows 8", DeviceDesktop},
+		"Mozilla/5.0 (Linux; U; Android 1.5; en-gb; T-Mobile_G2_Touch Build/CUPCAKE) AppleWebKit/528.5  (KHTML, like Gecko) Version/3.1.2 Mobile Safari/525.20.1": {
+			"Android Browser", "3", "Android", DeviceMobile},
+		"Mozilla/5.0 (Linux; Android 7.0; Nexus 9 Build/NRD90R) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/53.0.2785.124 Safari/537.36": {
+			"Chrome", "53", "Android", DeviceTablet},
+		"Mozilla/5.0 (iPad; CPU OS 8_4_1 like Mac OS X) AppleWebKit/600.1.4 (KHTML, like Gecko) Version/8.0 Mobile/12H321 Safari/600.1.4": {
+			"Safari", "8", "iOS", DeviceTablet},
+		"Mozilla/5.0 (Android; Mobile; rv:35.0) Gecko/35.0 Firefox/35.0": {
+			"Firefox", "35", "Android", DeviceMobile},
+		"Opera/9.80 (J2ME/MIDP; Opera Mini/5.0.16823/1428; U; en) Presto/2.2.0": {
+			"Opera Mini", "5", "J2ME", DeviceFeaturePhone},
+		"Googlebot/2.1 ( http://www.googlebot.com/bot.html)": {
+			"Googlebot", "", "Other", DeviceBot},
+		"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)": {
+			"bingbot", "", "Other", DeviceBot},
+		"Mozilla/5.0 (Linux; Android 9; CUBOT X19 Build/PPR1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/74.0.3729.136 Mobile Safari/537.36": {
+			"Chrome", "74", "Android", DeviceMobile},
+		"Adobe Application Manager 2.0": {
+			"Other", "", "Other", DeviceDesktop},
+	}
+
+	for s, expected := range cases {
+		if got := ParseUserAgent(s); got != expected {
+			t.Errorf("%s\nGot: %+v\nExpected: %+v", s, got, expected)
+		}
+	}
+}
+
+const groupTestUsers = `{"browsers":["Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2227.0 Safari/537.36","Mozilla/4.0 (compatible; MSIE 7.0; Windows NT 6.0; Trident/5.0)"],"email":"a@x.com","name":"Ann"}
+{"browsers":["Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2272.76 Safari/537.36"],"email":"b@x.com","name":"Bob"}
+{"browsers":["Googlebot/2.1 (+http://www.google.com/bot.html)"],"email":"c@x.com","name":"Cid"}
+{"browsers":["Opera/9.80 (J2ME/MIDP; Opera Mini/5.0.16823/1428; U; en) Presto/2.2.0"],"email":"d@x.com","name":"Dan"}`
+
+func TestGroupUsers(t *testing.T) {
+	groups, err := GroupUsers(strings.NewReader(groupTestUsers), Not(HasBrowser("Opera")), UserAgent.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []BrowserGroup{
+		{"Chrome 41 on Linux", 2, 2},
+		{"Googlebot on Other", 1, 1},
+		{"IE 7 on Windows Vista", 1, 1},
+	}
+	if fmt.Sprint(groups) != fmt.Sprint(expected) {
+		t.Errorf("Got: %+v\nExpected: %+v", groups, expected)
+	}
+}
+
+func TestGroupedByBrowser(t *testing.T) {
+	family := func(ua UserAgent) string { return ua.Family }
+
+	out := new(bytes.Buffer)
+	if err := Search(strings.NewReader(groupTestUsers), HasBrowser("Chrome"), GroupedByBrowser(NewTextWriter(out), family)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "found users:\n[0] Ann <a [at] x.com>\n[1] Bob <b [at] x.com>\n\nTotal unique browsers 2\n" +
+		"\nbrowsers:\nChrome: users 2, browsers 2\nIE: users 1, browsers 1\n"
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out, expected)
+	}
+
+	out.Reset()
+	w, _ := NewResultWriter("jsonl", out)
+	if err := Search(strings.NewReader(groupTestUsers), HasBrowser("bot"), GroupedByBrowser(w, family)); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = `{"index":2,"name":"Cid","email":"c [at] x.com"}` + "\n" +
+		`{"unique_browsers":1,"browsers":[{"key":"Googlebot","users":1,"browsers":1}]}` + "\n"
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out, expected)
+	}
+}
+
+func TestBrowserClassQuery(t *testing.T) {
+	names, _ := queryNames(t, HasBrowserFamily("IE"))
+	if len(names) != 2 || names[0] != "Ann" || names[1] != "Bob" {
+		t.Errorf("Got: %v, Expected: [Ann Bob]", names)
+	}
+
+	names, unique := queryNames(t, HasBrowserFamily("Opera"))
+	if len(names) != 1 || names[0] != "Dan" || unique != 1 {
+		t.Errorf("Got: %v %d, Expected: [Dan] 1", names, unique)
+	}
+}
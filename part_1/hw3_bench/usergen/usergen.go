@@ -0,0 +1,136 @@
+// Package usergen generates synthetic users in the data/users.txt format
+// for cmd/genusers and the synthetic benchmarks.
+package usergen
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+)
+
+// User is a line of data/users.txt
+type User struct {
+	Browsers []string `json:"browsers"`
+	Company  string   `json:"company"`
+	Country  string   `json:"country"`
+	Email    string   `json:"email"`
+	Job      string   `json:"job"`
+	Name     string   `json:"name"`
+	Phone    string   `json:"phone"`
+}
+
+// userAgent is a UA template, %d verbs are replaced by random numbers from 1 to max of the verb
+type userAgent struct {
+	weight   int
+	template string
+	max      []int
+}
+
+// weights roughly follow data/users.txt
+var userAgents = []userAgent{
+	{14, "Mozilla/5.0 (Linux; Android %d.%d; SM-G%d Build/MMB29K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.98 Mobile Safari/537.36", []int{7, 1, 950, 60, 3000}},
+	{8, "Mozilla/5.0 (Linux; U; Android %d.%d; en-us; Droid Build/ESD%d) AppleWebKit/530.17 (KHTML, like Gecko) Version/4.0 Mobile Safari/530.17", []int{3, 3, 30}},
+	{6, "Mozilla/5.0 (Android; Mobile; rv:%d.0) Gecko/%d.0 Firefox/%d.0", []int{60, 60, 60}},
+	{5, "Opera/9.80 (Android; Opera Mini/%d.5.%d/31.1543; U; en) Presto/2.8.119 Version/11.1010", []int{8, 40000}},
+	{10, "Mozilla/4.0 (compatible; MSIE %d.0; Windows NT %d.1; Trident/%d.0)", []int{11, 10, 7}},
+	{6, "Mozilla/5.0 (compatible; MSIE %d.0; Windows Phone %d.0; Trident/%d.0; IEMobile/10.0; ARM; Touch; NOKIA; Lumia 920)", []int{10, 8, 7}},
+	{5, "Mozilla/4.0 (compatible; MSIE 6.0; Windows CE; IEMobile %d.%d)", []int{9, 12}},
+	{5, "Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:%d.0) like Gecko", []int{11}},
+	{12, "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Safari/537.36", []int{60, 3000, 150}},
+	{10, "Mozilla/5.0 (Windows NT %d.1; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/%d.0.%d.%d Safari/537.36", []int{10, 60, 3000, 150}},
+	{8, "Mozilla/5.0 (Windows NT %d.1; rv:%d.0) Gecko/20100101 Firefox/%d.0", []int{10, 60, 60}},
+	{8, "Mozilla/5.0 (iPad; CPU OS %d_%d like Mac OS X) AppleWebKit/536.26 (KHTML, like Gecko) Version/%d.0 Mobile/10A5355d Safari/8536.25", []int{9, 3, 9}},
+	{6, "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_%d_%d) AppleWebKit/534.55.3 (KHTML, like Gecko) Version/%d.1.3 Safari/534.53.10", []int{11, 5, 9}},
+	{4, "Opera/9.80 (X11; Linux i686) Presto/2.%d.%d Version/12.%d", []int{12, 400, 16}},
+	{4, "Mozilla/5.0 (SymbianOS/9.%d; U; Series60/3.1 NokiaE90-1/07.%d.0.3; Profile/MIDP-2.0 Configuration/CLDC-1.1 ) AppleWebKit/413 (KHTML, like Gecko) Safari/413", []int{4, 30}},
+	{3, "LG-LX%d AU-MIC-LX%d/2.0 MMP/2.0 Profile/MIDP-2.0 Configuration/CLDC-1.1", []int{900, 900}},
+}
+
+var (
+	firstNames = []string{"Sharon", "Jonathan", "Maria", "David", "Linda", "James", "Patricia", "Robert", "Jennifer", "Michael", "Elizabeth", "William", "Susan", "Richard", "Jessica", "Thomas", "Sarah", "Charles", "Karen", "Daniel"}
+	lastNames  = []string{"Crawford", "Morris", "Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Jackson", "Martin", "Lee"}
+	companies  = []string{"Flashpoint", "Muxo", "Skinix", "Yodel", "Topicshots", "Quimba", "Jabbertype", "Realbridge", "Zoomzone", "Devpulse", "Trudeo", "Linktype", "Wordware", "Blogtag", "Feedfire"}
+	domains    = []string{"com", "org", "net", "edu", "info", "biz", "gov", "mil"}
+	countries  = []string{"Dominican Republic", "Russia", "United States", "Germany", "Brazil", "India", "Japan", "France", "Canada", "Nigeria", "Mexico", "Poland"}
+	jobs       = []string{"Programmer Analyst", "Accountant", "Web Designer", "Nurse", "Structural Engineer", "Marketing Manager", "Geologist", "Help Desk Operator", "Account Executive", "Sales Associate"}
+)
+
+type generator struct {
+	rnd         *rand.Rand
+	totalWeight int
+}
+
+func newGenerator(seed int64) *generator {
+	g := &generator{rnd: rand.New(rand.NewSource(seed))}
+	for _, ua := range userAgents {
+		g.totalWeight += ua.weight
+	}
+	return g
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rnd.Intn(len(values))]
+}
+
+func (g *generator) userAgent() string {
+	n := g.rnd.Intn(g.totalWeight)
+
+	ua := userAgents[0]
+	for _, ua = range userAgents {
+		if n < ua.weight {
+			break
+		}
+		n -= ua.weight
+	}
+
+	args := make([]interface{}, len(ua.max))
+	for i, max := range ua.max {
+		args[i] = g.rnd.Intn(max) + 1
+	}
+	return fmt.Sprintf(ua.template, args...)
+}
+
+func (g *generator) user() User {
+	first, last := g.pick(firstNames), g.pick(lastNames)
+
+	u := User{
+		Company: g.pick(companies),
+		Country: g.pick(countries),
+		Email:   g.pick(firstNames) + g.pick(lastNames) + "@" + g.pick(companies) + "." + g.pick(domains),
+		Job:     g.pick(jobs) + " #" + strconv.Itoa(g.rnd.Intn(10)+1),
+		Name:    first + " " + last,
+		Phone:   fmt.Sprintf("%03d-%02d-%02d", g.rnd.Intn(1000), g.rnd.Intn(100), g.rnd.Intn(100)),
+	}
+	for i := g.rnd.Intn(7) + 1; i > 0; i-- {
+		u.Browsers = append(u.Browsers, g.userAgent())
+	}
+
+	return u
+}
+
+// Generate writes newline separated users until size bytes or count users are written,
+// whichever limit is set and reached first. The same seed always gives the same users.
+func Generate(out io.Writer, seed, size int64, count int) (int, error) {
+	g := newGenerator(seed)
+	w := bufio.NewWriter(out)
+
+	var written int64
+	var n int
+	for ; (size <= 0 || written < size) && (count <= 0 || n < count); n++ {
+		line, err := json.Marshal(g.user())
+		if err != nil {
+			return n, err
+		}
+		if n > 0 {
+			w.WriteByte('\n')
+			written++
+		}
+		w.Write(line)
+		written += int64(len(line))
+	}
+
+	return n, w.Flush()
+}
@@ -0,0 +1,68 @@
+package usergen
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGenerateReproducible(t *testing.T) {
+	a, b := new(bytes.Buffer), new(bytes.Buffer)
+	if _, err := Generate(a, 42, 64<<10, 0); err != nil {
+		t.Fatal(err)
+	}
+	Generate(b, 42, 64<<10, 0)
+
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("same seed produced different files")
+	}
+	if a.Len() < 64<<10 || a.Len() > 64<<10+4096 {
+		t.Errorf("size %d is far from the target", a.Len())
+	}
+
+	c := new(bytes.Buffer)
+	Generate(c, 43, 64<<10, 0)
+	if bytes.Equal(a.Bytes(), c.Bytes()) {
+		t.Error("different seeds produced the same file")
+	}
+}
+
+func TestGenerateFormat(t *testing.T) {
+	out := new(bytes.Buffer)
+	n, err := Generate(out, 1, 0, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if n != 200 || len(lines) != 200 {
+		t.Fatalf("expected 200 users, got %d in %d lines", n, len(lines))
+	}
+
+	var android, msie bool
+	for _, line := range lines {
+		var u User
+		if err := json.Unmarshal([]byte(line), &u); err != nil {
+			t.Fatal(err)
+		}
+		if len(u.Browsers) == 0 || !strings.Contains(u.Email, "@") || u.Name == "" {
+			t.Fatalf("bad user %+v", u)
+		}
+		for _, browser := range u.Browsers {
+			android = android || strings.Contains(browser, "Android")
+			msie = msie || strings.Contains(browser, "MSIE")
+		}
+	}
+	if !android || !msie {
+		t.Error("FastSearch browsers are not generated")
+	}
+}
+
+func TestUserAgentVerbs(t *testing.T) {
+	for _, ua := range userAgents {
+		if strings.Count(ua.template, "%d") != len(ua.max) {
+			t.Errorf("%s: %d verbs, %d max values", ua.template, strings.Count(ua.template, "%d"), len(ua.max))
+		}
+	}
+}
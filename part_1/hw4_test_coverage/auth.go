@@ -0,0 +1,184 @@
+package search
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator добавляет к запросу SearchClient данные для авторизации, вызывается перед каждой попыткой
+type Authenticator interface {
+	Authenticate(r *http.Request) error
+}
+
+// Verifier проверяет авторизацию запроса на стороне SearchServer
+type Verifier interface {
+	Verify(r *http.Request) error
+}
+
+var errNotAuthorized = errors.New("not authorized")
+
+// StaticToken передает токен в хедере AccessToken, как раньше SearchClient.AccessToken
+type StaticToken string
+
+func (t StaticToken) Authenticate(r *http.Request) error {
+	r.Header.Set("AccessToken", string(t))
+	return nil
+}
+
+// StaticTokens принимает запросы с любым из токенов в хедере AccessToken
+type StaticTokens []string
+
+func (tokens StaticTokens) Verify(r *http.Request) error {
+	token := r.Header.Get("AccessToken")
+	for _, t := range tokens {
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return nil
+		}
+	}
+	return errNotAuthorized
+}
+
+const (
+	timestampHeader = "X-Timestamp"
+	signatureHeader = "X-Signature"
+)
+
+// HMACAuth подписывает метод, путь с параметрами и время запроса общим секретом
+type HMACAuth struct {
+	Secret []byte
+	// для тестов, по умолчанию time.Now
+	Now func() time.Time
+}
+
+func signRequest(secret []byte, r *http.Request, timestamp string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + timestamp))
+	return mac.Sum(nil)
+}
+
+func (a HMACAuth) Authenticate(r *http.Request) error {
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	r.Header.Set(timestampHeader, timestamp)
+	r.Header.Set(signatureHeader, hex.EncodeToString(signRequest(a.Secret, r, timestamp)))
+	return nil
+}
+
+// HMACVerifier проверяет подпись HMACAuth и отклоняет запросы, время которых отличается от текущего больше чем на MaxSkew.
+// От повтора это не защищает: перехваченный запрос можно повторить в пределах MaxSkew
+type HMACVerifier struct {
+	// с пустым секретом не принимается ни один запрос
+	Secret []byte
+	// по умолчанию 5 минут
+	MaxSkew time.Duration
+	Now     func() time.Time
+}
+
+func (v HMACVerifier) Verify(r *http.Request) error {
+	if len(v.Secret) == 0 {
+		return errNotAuthorized
+	}
+
+	now, maxSkew := time.Now, v.MaxSkew
+	if v.Now != nil {
+		now = v.Now
+	}
+	if maxSkew == 0 {
+		maxSkew = 5 * time.Minute
+	}
+
+	timestamp := r.Header.Get(timestampHeader)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errNotAuthorized
+	}
+	if skew := now().Sub(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return errNotAuthorized
+	}
+
+	signature, err := hex.DecodeString(r.Header.Get(signatureHeader))
+	if err != nil || !hmac.Equal(signature, signRequest(v.Secret, r, timestamp)) {
+		return errNotAuthorized
+	}
+	return nil
+}
+
+// Invalidator сбрасывает сохраненные данные авторизации.
+// Если Authenticator его реализует, SearchClient после ответа 401 вызывает Invalidate и один раз повторяет запрос
+type Invalidator interface {
+	Invalidate()
+}
+
+// TokenSource выдает bearer токен и время, до которого он действует
+type TokenSource interface {
+	Token(ctx context.Context) (token string, expiry time.Time, err error)
+}
+
+// BearerToken передает токен из Source в хедере Authorization и запрашивает новый,
+// когда до истечения текущего остается меньше Leeway
+type BearerToken struct {
+	Source TokenSource
+	Leeway time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (b *BearerToken) Authenticate(r *http.Request) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.token == "" || !time.Now().Add(b.Leeway).Before(b.expiry) {
+		token, expiry, err := b.Source.Token(r.Context())
+		if err != nil {
+			return err
+		}
+		b.token, b.expiry = token, expiry
+	}
+
+	r.Header.Set("Authorization", "Bearer "+b.token)
+	return nil
+}
+
+// Invalidate сбрасывает токен, следующий запрос получит новый из Source
+func (b *BearerToken) Invalidate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.token, b.expiry = "", time.Time{}
+}
+
+// BearerVerifier проверяет токен из хедера Authorization функцией Validate
+type BearerVerifier func(token string) error
+
+func (validate BearerVerifier) Verify(r *http.Request) error {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return errNotAuthorized
+	}
+	return validate(token)
+}
+
+// RequireAuth отвечает 401 на запросы, не прошедшие проверку v
+func RequireAuth(v Verifier, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := v.Verify(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,148 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestHMACAuth(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+	now := time.Unix(1700000000, 0)
+	srv.Auth = HMACVerifier{Secret: []byte("secret"), Now: func() time.Time { return now }}
+
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+
+	searchClient := &SearchClient{URL: searchService.URL, Auth: HMACAuth{Secret: []byte("secret"), Now: func() time.Time { return now }}}
+	if _, err := searchClient.FindUsers(SearchRequest{Limit: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]Authenticator{
+		"wrong secret": HMACAuth{Secret: []byte("guess"), Now: func() time.Time { return now }},
+		"old request":  HMACAuth{Secret: []byte("secret"), Now: func() time.Time { return now.Add(-time.Hour) }},
+		"static token": StaticToken(testToken),
+	}
+	for name, auth := range cases {
+		searchClient.Auth = auth
+		if _, err := searchClient.FindUsers(SearchRequest{Limit: 1}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("%s: expected ErrUnauthorized, got %v", name, err)
+		}
+	}
+
+	// подпись не переносится на другие параметры
+	r := httptest.NewRequest("GET", "/?limit=1&offset=0&order_by=0", nil)
+	HMACAuth{Secret: []byte("secret"), Now: func() time.Time { return now }}.Authenticate(r)
+	r.URL.RawQuery = "limit=25&offset=0&order_by=0"
+	if err := srv.Auth.Verify(r); err == nil {
+		t.Error("tampered request is verified")
+	}
+
+	// без секрета подпись может посчитать кто угодно
+	r = httptest.NewRequest("GET", "/?limit=1&offset=0&order_by=0", nil)
+	HMACAuth{Now: func() time.Time { return now }}.Authenticate(r)
+	if err := (HMACVerifier{Now: func() time.Time { return now }}).Verify(r); err == nil {
+		t.Error("request is verified with empty secret")
+	}
+}
+
+type countingTokenSource struct {
+	calls int
+	ttl   time.Duration
+}
+
+func (s *countingTokenSource) Token(ctx context.Context) (string, time.Time, error) {
+	s.calls++
+	return "token" + strconv.Itoa(s.calls), time.Now().Add(s.ttl), nil
+}
+
+func TestBearerToken(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+	var tokens []string
+	srv.Auth = BearerVerifier(func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+
+	source := &countingTokenSource{ttl: time.Hour}
+	searchClient := &SearchClient{URL: searchService.URL, Auth: &BearerToken{Source: source, Leeway: time.Minute}}
+
+	for i := 0; i < 3; i++ {
+		if _, err := searchClient.FindUsers(SearchRequest{Limit: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if source.calls != 1 || tokens[2] != "token1" {
+		t.Errorf("token is not reused: %d calls, tokens %v", source.calls, tokens)
+	}
+
+	// токен, истекающий раньше Leeway, обновляется перед каждым запросом
+	source.ttl = 30 * time.Second
+	searchClient.Auth = &BearerToken{Source: source, Leeway: time.Minute}
+	searchClient.FindUsers(SearchRequest{Limit: 1})
+	searchClient.FindUsers(SearchRequest{Limit: 1})
+	if source.calls != 3 || tokens[4] != "token3" {
+		t.Errorf("expiring token is not refreshed: %d calls, tokens %v", source.calls, tokens)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if err := srv.Auth.Verify(r); err == nil {
+		t.Error("request without token is verified")
+	}
+}
+
+func TestBearerTokenRevoked(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+	revoked := map[string]bool{"token1": true}
+	srv.Auth = BearerVerifier(func(token string) error {
+		if revoked[token] {
+			return errNotAuthorized
+		}
+		return nil
+	})
+
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+
+	// token1 отозван раньше срока, клиент должен взять новый и повторить запрос
+	source := &countingTokenSource{ttl: time.Hour}
+	searchClient := &SearchClient{URL: searchService.URL, Auth: &BearerToken{Source: source}}
+	if _, err := searchClient.FindUsers(SearchRequest{Limit: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != 2 {
+		t.Errorf("expected a new token after 401, got %d calls", source.calls)
+	}
+
+	// повторяется только один раз
+	revoked["token2"], revoked["token3"], revoked["token4"] = true, true, true
+	if _, err := searchClient.FindUsers(SearchRequest{Limit: 1}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+	if source.calls != 3 {
+		t.Errorf("expected a single retry, got %d calls", source.calls)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	handler := RequireAuth(StaticTokens{"a", "b"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for token, code := range map[string]int{"a": http.StatusOK, "b": http.StatusOK, "c": http.StatusUnauthorized, "": http.StatusUnauthorized} {
+		r := httptest.NewRequest("GET", "/", nil)
+		StaticToken(token).Authenticate(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != code {
+			t.Errorf("token %q: expected %d, got %d", token, code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package search
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ResponseCache хранит ответы SearchClient по урлу с параметрами поиска.
+// Свежие ответы отдаются без запроса, устаревшие с ETag перепроверяются через If-None-Match.
+// Токен в ключ не входит, поэтому кеш не стоит делить между клиентами с разными правами
+type ResponseCache struct {
+	TTL        time.Duration
+	MaxEntries int
+	// для тестов, по умолчанию time.Now
+	Now func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+func NewResponseCache(maxEntries int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{TTL: ttl, MaxEntries: maxEntries}
+}
+
+// lazyInit позволяет использовать ResponseCache без конструктора, вызывается под mu
+func (c *ResponseCache) lazyInit() {
+	if c.ll == nil {
+		c.ll = list.New()
+		c.items = make(map[string]*list.Element)
+	}
+}
+
+func (c *ResponseCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// get возвращает запись и признак свежести, устаревшие записи без ETag не возвращаются
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lazyInit()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+
+	if c.now().Before(entry.expires) {
+		c.ll.MoveToFront(el)
+		return entry, true
+	}
+	if entry.etag == "" {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	return entry, false
+}
+
+func (c *ResponseCache) put(key string, body []byte, etag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lazyInit()
+
+	entry := &cacheEntry{key, body, etag, c.now().Add(c.TTL)}
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(entry)
+	for c.MaxEntries > 0 && c.ll.Len() > c.MaxEntries {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lazyInit()
+	return c.ll.Len()
+}
@@ -0,0 +1,131 @@
+package search
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+
+	var requests, notModified int32
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, r)
+		if rec.Code == http.StatusNotModified {
+			atomic.AddInt32(&notModified, 1)
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer searchService.Close()
+
+	now := time.Unix(1700000000, 0)
+	cache := NewResponseCache(2, time.Minute)
+	cache.Now = func() time.Time { return now }
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL, Cache: cache}
+
+	find := func(req SearchRequest) *SearchResponse {
+		t.Helper()
+		result, err := searchClient.FindUsers(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	first := find(SearchRequest{Limit: 1})
+	second := find(SearchRequest{Limit: 1})
+	if requests != 1 || first.Users[0] != second.Users[0] || !second.NextPage {
+		t.Errorf("fresh response is not cached: %d requests", requests)
+	}
+
+	// устаревший ответ перепроверяется и не скачивается заново
+	now = now.Add(2 * time.Minute)
+	third := find(SearchRequest{Limit: 1})
+	if requests != 2 || notModified != 1 || third.Users[0] != first.Users[0] {
+		t.Errorf("stale response is not revalidated: %d requests, %d not modified", requests, notModified)
+	}
+	find(SearchRequest{Limit: 1})
+	if requests != 2 {
+		t.Errorf("revalidated response is not fresh: %d requests", requests)
+	}
+
+	// размер ограничен, вытесняется давно не использованный
+	find(SearchRequest{Limit: 2})
+	find(SearchRequest{Limit: 3})
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+	find(SearchRequest{Limit: 1})
+	if requests != 5 {
+		t.Errorf("evicted response is served from cache: %d requests", requests)
+	}
+}
+
+func TestResponseCacheErrorsNotCached(t *testing.T) {
+	var requests int32
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer searchService.Close()
+
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL, Cache: NewResponseCache(10, time.Minute)}
+	searchClient.FindUsers(SearchRequest{})
+	searchClient.FindUsers(SearchRequest{})
+
+	if requests != 2 || searchClient.Cache.Len() != 0 {
+		t.Errorf("error responses must not be cached: %d requests, %d entries", requests, searchClient.Cache.Len())
+	}
+}
+
+func TestResponseCacheLiteral(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+
+	var requests int32
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		srv.ServeHTTP(w, r)
+	}))
+	defer searchService.Close()
+
+	cache := &ResponseCache{TTL: time.Minute}
+	if cache.Len() != 0 {
+		t.Errorf("empty cache has %d entries", cache.Len())
+	}
+
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL, Cache: cache}
+	for i := 0; i < 2; i++ {
+		if _, err := searchClient.FindUsers(SearchRequest{Limit: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if requests != 1 || cache.Len() != 1 {
+		t.Errorf("expected 1 request and 1 entry, got %d requests, %d entries", requests, cache.Len())
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	cases := map[string]bool{
+		`"a"`:      true,
+		`"b", "a"`: true,
+		`W/"a"`:    true,
+		`*`:        true,
+		`"b"`:      false,
+		``:         false,
+	}
+	for header, expected := range cases {
+		if etagMatches(header, `"a"`) != expected {
+			t.Errorf("%q: expected %v", header, expected)
+		}
+	}
+}
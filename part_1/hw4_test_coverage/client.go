@@ -1,6 +1,7 @@
-package main
+package search
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,6 +34,8 @@ type User struct {
 type SearchResponse struct {
 	Users    []User
 	NextPage bool
+	// только в режиме курсора, пустой на последней странице
+	NextCursor string
 }
 
 type SearchErrorResponse struct {
@@ -54,6 +57,18 @@ type SearchRequest struct {
 	OrderField string
 	// -1 по убыванию, 0 как встретилось, 1 по возрастанию
 	OrderBy int
+	// сортировка по нескольким полям, если задана - OrderField и OrderBy не используются
+	Order []OrderKey
+	// фильтры, нулевые значения не фильтруют
+	AgeMin int
+	AgeMax int
+	Gender string
+	IdIn   []int
+	// режим курсора: страницы не сдвигаются при изменении данных, Offset не используется.
+	// Первая страница запрашивается с UseCursor, следующие - с Cursor из SearchResponse.NextCursor.
+	// Пользователи с равными ключами сортировки упорядочены по Id
+	UseCursor bool
+	Cursor    string
 }
 
 type SearchClient struct {
@@ -61,10 +76,30 @@ type SearchClient struct {
 	AccessToken string
 	// урл внешней системы, куда идти
 	URL string
+	// если не задан, используется StaticToken(AccessToken)
+	Auth Authenticator
+	// если не задан, используется клиент с таймаутом в 1 секунду
+	HTTPClient *http.Client
+	// по умолчанию повторов нет
+	Retry RetryPolicy
+	// по умолчанию ответы не кешируются
+	Cache *ResponseCache
 }
 
 // FindUsers отправляет запрос во внешнюю систему, которая непосредственно ищет пользоваталей
 func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
+	return srv.FindUsersContext(context.Background(), req)
+}
+
+func (srv *SearchClient) httpClient() *http.Client {
+	if srv.HTTPClient != nil {
+		return srv.HTTPClient
+	}
+	return client
+}
+
+// FindUsersContext - FindUsers с отменой через ctx, таймауты и 5xx ответы повторяются согласно srv.Retry
+func (srv *SearchClient) FindUsersContext(ctx context.Context, req SearchRequest) (*SearchResponse, error) {
 
 	searcherParams := url.Values{}
 
@@ -78,49 +113,81 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 		return nil, fmt.Errorf("offset must be > 0")
 	}
 
+	useCursor := req.UseCursor || req.Cursor != ""
+
 	//нужно для получения следующей записи, на основе которой мы скажем - можно показать переключатель следующей страницы или нет
-	req.Limit++
+	if !useCursor {
+		req.Limit++
+	}
 
 	searcherParams.Add("limit", strconv.Itoa(req.Limit))
 	searcherParams.Add("offset", strconv.Itoa(req.Offset))
 	searcherParams.Add("query", req.Query)
 	searcherParams.Add("order_field", req.OrderField)
 	searcherParams.Add("order_by", strconv.Itoa(req.OrderBy))
+	if len(req.Order) > 0 {
+		searcherParams.Add("order", EncodeOrder(req.Order))
+	}
+	if req.AgeMin > 0 {
+		searcherParams.Add("age_min", strconv.Itoa(req.AgeMin))
+	}
+	if req.AgeMax > 0 {
+		searcherParams.Add("age_max", strconv.Itoa(req.AgeMax))
+	}
+	if req.Gender != "" {
+		searcherParams.Add("gender", req.Gender)
+	}
+	if len(req.IdIn) > 0 {
+		searcherParams.Add("id_in", encodeIds(req.IdIn))
+	}
+	if useCursor {
+		searcherParams.Add("cursor", req.Cursor)
+	}
 
-	searcherReq, err := http.NewRequest("GET", srv.URL+"?"+searcherParams.Encode(), nil)
-	searcherReq.Header.Add("AccessToken", srv.AccessToken)
-	
-	resp, err := client.Do(searcherReq)
+	status, body, err := srv.fetch(ctx, searcherParams)
 	if err != nil {
-		if err, ok := err.(net.Error); ok && err.Timeout() {
-			return nil, fmt.Errorf("timeout for %s", searcherParams.Encode())
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return nil, fmt.Errorf("%w for %s: %w", ErrTimeout, searcherParams.Encode(), err)
 		}
-		return nil, fmt.Errorf("unknown error %s", err)
+		return nil, fmt.Errorf("unknown error %w", err)
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
 
-	switch resp.StatusCode {
+	switch status {
 	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("Bad AccessToken")
-	case http.StatusInternalServerError:
-		return nil, fmt.Errorf("SearchServer fatal error")
+		return nil, ErrUnauthorized
 	case http.StatusBadRequest:
 		errResp := SearchErrorResponse{}
 		err = json.Unmarshal(body, &errResp)
 		if err != nil {
-			return nil, fmt.Errorf("cant unpack error json: %s", err)
+			return nil, fmt.Errorf("cant unpack error json: %w", err)
 		}
 		if errResp.Error == "ErrorBadOrderField" {
-			return nil, fmt.Errorf("OrderFeld %s invalid", req.OrderField)
+			if len(req.Order) > 0 {
+				return nil, ErrBadOrderField{EncodeOrder(req.Order)}
+			}
+			return nil, ErrBadOrderField{req.OrderField}
+		}
+		if errResp.Error == "ErrorBadCursor" {
+			return nil, ErrBadCursor
 		}
 		return nil, fmt.Errorf("unknown bad request error: %s", errResp.Error)
 	}
+	if status >= 500 {
+		return nil, ErrServer{status, string(body)}
+	}
+
+	if useCursor {
+		page := cursorPage{}
+		if err = json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("cant unpack result json: %w", err)
+		}
+		return &SearchResponse{Users: page.Users, NextPage: page.NextCursor != "", NextCursor: page.NextCursor}, nil
+	}
 
 	data := []User{}
 	err = json.Unmarshal(body, &data)
 	if err != nil {
-		return nil, fmt.Errorf("cant unpack result json: %s", err)
+		return nil, fmt.Errorf("cant unpack result json: %w", err)
 	}
 
 	result := SearchResponse{}
@@ -131,5 +198,95 @@ func (srv *SearchClient) FindUsers(req SearchRequest) (*SearchResponse, error) {
 		result.Users = data[0:len(data)]
 	}
 
-	return &result, err
+	return &result, nil
+}
+
+// fetch возвращает код и тело ответа из кеша или с повторами согласно srv.Retry
+func (srv *SearchClient) fetch(ctx context.Context, params url.Values) (int, []byte, error) {
+	key := srv.URL + "?" + params.Encode()
+
+	var cached *cacheEntry
+	if srv.Cache != nil {
+		entry, fresh := srv.Cache.get(key)
+		if fresh {
+			return http.StatusOK, entry.body, nil
+		}
+		cached = entry
+	}
+
+	var etag string
+	if cached != nil {
+		etag = cached.etag
+	}
+
+	var resp *http.Response
+	var body []byte
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, body, err = srv.doReauth(ctx, params, etag)
+		if !srv.Retry.retryable(resp, err) || attempt >= srv.Retry.MaxRetries {
+			break
+		}
+		// прошлый ответ уже не важен, вызывающему нужна причина отмены
+		if err := srv.Retry.wait(ctx, attempt); err != nil {
+			return 0, nil, err
+		}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if srv.Cache != nil {
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached != nil:
+			srv.Cache.put(key, cached.body, cached.etag)
+			return http.StatusOK, cached.body, nil
+		case resp.StatusCode == http.StatusOK:
+			srv.Cache.put(key, body, resp.Header.Get("ETag"))
+		}
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+func (srv *SearchClient) authenticator() Authenticator {
+	if srv.Auth == nil {
+		return StaticToken(srv.AccessToken)
+	}
+	return srv.Auth
+}
+
+// doReauth повторяет запрос один раз после 401, если данные авторизации можно сбросить
+func (srv *SearchClient) doReauth(ctx context.Context, params url.Values, etag string) (*http.Response, []byte, error) {
+	resp, body, err := srv.do(ctx, params, etag)
+	inv, ok := srv.authenticator().(Invalidator)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !ok {
+		return resp, body, err
+	}
+
+	inv.Invalidate()
+	return srv.do(ctx, params, etag)
+}
+
+// do делает одну попытку запроса, etag уходит в If-None-Match
+func (srv *SearchClient) do(ctx context.Context, params url.Values, etag string) (*http.Response, []byte, error) {
+	searcherReq, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if etag != "" {
+		searcherReq.Header.Set("If-None-Match", etag)
+	}
+	if err := srv.authenticator().Authenticate(searcherReq); err != nil {
+		return nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+
+	resp, err := srv.httpClient().Do(searcherReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp, body, err
 }
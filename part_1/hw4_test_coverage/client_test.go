@@ -1,240 +1,37 @@
-package main
+package search
 
 import (
-	"encoding/json"
-	"encoding/xml"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-/*
-	Data structs
-*/
-
-type XmlUser struct {
-	Id         int    `xml:"id" json:"id"`
-	Name       string `xml:"-" json:"-"`
-	FirstName  string `xml:"first_name" json:"-"`
-	SecondName string `xml:"last_name" json:"-"`
-	Age        int    `xml:"age" json:"age"`
-	About      string `xml:"about" json:"about"`
-	Gender     string `xml:"gender" json:"gender"`
-}
-
-type XmlUsers struct {
-	XMLName xml.Name  `xml:"root"`
-	Users   []XmlUser `xml:"row"`
-}
-
-func (u *XmlUser) compareById(other *XmlUser) int {
-	if u.Id > other.Id {
-		return OrderByDesc
-	}
-
-	if u.Id < other.Id {
-		return OrderByAsc
-	}
-
-	return OrderByAsIs
-}
-
-func (u *XmlUser) compareByAge(other *XmlUser) int {
-	if u.Age > other.Age {
-		return OrderByDesc
-	}
-
-	if u.Age < other.Age {
-		return OrderByAsc
-	}
-
-	return OrderByAsIs
-}
-
-func (u *XmlUser) compareByName(other *XmlUser) int {
-	name := u.getFullName()
-	name2 := other.getFullName()
-	if name > name2 {
-		return OrderByDesc
-	}
-
-	if name < name2 {
-		return OrderByAsc
-	}
-
-	return OrderByAsIs
-}
-
-func (u *XmlUser) getFullName() string {
-	return u.FirstName + " " + u.SecondName
-}
-
-func (u *XmlUser) MarshalJSON() ([]byte, error) {
-	type Copy XmlUser
-
-	return json.Marshal(&struct {
-		Name string `json:"name"`
-		*Copy
-	}{
-		Name: u.getFullName(),
-		Copy: (*Copy)(u),
-	})
-}
-
 /*
 	SearchServer part
 */
 
-type SearchServer struct {
-	pathToFile string
-}
-
-func (ss *SearchServer) getUsers(params *SearchRequest) ([]XmlUser, error) {
-	rawUsers, err := getUsersFromFile(ss.pathToFile)
-	if err != nil {
-		return nil, err
-	}
-
-	var resultUsers []XmlUser
-
-	if params.Query == "" {
-		resultUsers = rawUsers
-	} else {
-		for _, user := range rawUsers {
-			nameContainsQuery := strings.Contains(user.getFullName(), params.Query)
-			aboutContainsQuery := strings.Contains(user.About, params.Query)
-
-			if nameContainsQuery || aboutContainsQuery {
-				resultUsers = append(resultUsers, user)
-			}
-		}
-	}
-
-	if params.OrderBy != 0 && params.OrderField != "" {
-		sortUsers(resultUsers, params.OrderField, params.OrderBy)
-	}
-
-	if params.Offset+params.Limit > len(resultUsers) {
-		return resultUsers[params.Offset:], nil
-	}
-
-	return resultUsers[params.Offset:params.Limit], nil
-}
-
-func getUsersFromFile(pathToFile string) ([]XmlUser, error) {
-	file, err := os.Open(pathToFile)
-	if err != nil {
-		return nil, errors.New("invalid resource path")
-	}
-	defer func() { _ = file.Close() }()
-
-	var usersList XmlUsers
-	if err := xml.NewDecoder(file).Decode(&usersList); err != nil {
-		return nil, errors.New("file decoding error")
-	}
-
-	return usersList.Users, nil
-}
-
-func sortUsers(users []XmlUser, orderField string, orderBy int) {
-	sort.Slice(users, func(i, j int) bool {
-		if orderField == "Age" {
-			return users[i].compareByAge(&users[j]) == orderBy
-		}
-
-		if orderField == "Name" {
-			return users[i].compareByName(&users[j]) == orderBy
-		}
-
-		return users[i].compareById(&users[j]) == orderBy
-	})
-}
+var (
+	testServerOnce sync.Once
+	testServer     *SearchServer
+)
 
 func SearchServerHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	token := r.Header.Get("AccessToken")
-	if token == "" {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	if token != testToken {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	searchRequest, err := getValidatedInput(r)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-
-		if err.Error() == ErrorBadOrderField {
-			_, _ = io.WriteString(w, fmt.Sprintf(`{"StatusCode": 400, "Error": "`+ErrorBadOrderFieldMsg+`"}`))
-		} else {
-			_, _ = io.WriteString(w, fmt.Sprintf(`{"StatusCode": 400, "OrderField": "%s"}`, err.Error()))
+	testServerOnce.Do(func() {
+		var err error
+		if testServer, err = NewSearchServer("./dataset.xml", testToken); err != nil {
+			panic(err)
 		}
-
-		return
-	}
-
-	searchServer := SearchServer{"./dataset.xml"}
-
-	users, err := searchServer.getUsers(searchRequest)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = io.WriteString(w, fmt.Sprintf(`{"StatusCode": 500, "error": "%s"}`, err.Error()))
-		return
-	}
-
-	usersJson, err := json.Marshal(users)
-
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_, _ = io.WriteString(w, fmt.Sprintf(`{"StatusCode": 500, "error": "Invalid data for json encoding"}`))
-		return
-	}
-
-	_, _ = io.WriteString(w, string(usersJson))
-}
-
-func getValidatedInput(r *http.Request) (*SearchRequest, error) {
-	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
-
-	if err != nil {
-		return nil, errors.New("limit")
-	}
-
-	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
-
-	if err != nil {
-		return nil, errors.New("offset")
-	}
-
-	orderBy, err := strconv.Atoi(r.URL.Query().Get("order_by"))
-
-	if err != nil {
-		return nil, errors.New("order_by")
-
-	}
-
-	orderField := r.URL.Query().Get("order_field")
-	if orderField == "" {
-		return nil, errors.New(ErrorBadOrderFieldMsg)
-	}
-
-	query := r.URL.Query().Get("query")
-
-	return &SearchRequest{
-		limit, offset, query, orderField, orderBy,
-	}, nil
+	})
+	testServer.ServeHTTP(w, r)
 }
 
 /*
@@ -290,7 +87,7 @@ func TestRequestOffsetBelowZero(t *testing.T) {
 func TestNoToken(t *testing.T) {
 	searchService := httptest.NewServer(http.HandlerFunc(SearchServerHandler))
 	defer searchService.Close()
-	searchClient := &SearchClient{"Wrong token", searchService.URL}
+	searchClient := &SearchClient{AccessToken: "Wrong token", URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{})
 
@@ -309,7 +106,7 @@ func TestLongServerResponse(t *testing.T) {
 	}))
 
 	defer searchService.Close()
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{})
 
@@ -321,7 +118,7 @@ func TestLongServerResponse(t *testing.T) {
 }
 
 func TestEmptyUrl(t *testing.T) {
-	searchClient := &SearchClient{testToken, ""}
+	searchClient := &SearchClient{AccessToken: testToken}
 
 	_, err := searchClient.FindUsers(SearchRequest{})
 
@@ -339,7 +136,7 @@ func TestServer500Code(t *testing.T) {
 	}))
 
 	defer searchService.Close()
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{})
 
@@ -359,7 +156,7 @@ func TestOrderFieldValidationErrors(t *testing.T) {
 
 	defer searchService.Close()
 
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{OrderField: "smth"})
 
@@ -379,7 +176,7 @@ func TestOrderFieldValidationWrongJson(t *testing.T) {
 
 	defer searchService.Close()
 
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{})
 
@@ -399,7 +196,7 @@ func TestValidationErrors(t *testing.T) {
 
 	defer searchService.Close()
 
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{OrderField: "smth"})
 
@@ -413,7 +210,7 @@ func TestValidationErrors(t *testing.T) {
 func TestCorrectRequest(t *testing.T) {
 	searchService := httptest.NewServer(http.HandlerFunc(SearchServerHandler))
 	defer searchService.Close()
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	result, err := searchClient.FindUsers(
 		SearchRequest{
@@ -435,7 +232,7 @@ func TestCorrectRequest(t *testing.T) {
 func TestCorrectMaximumLimit(t *testing.T) {
 	searchService := httptest.NewServer(http.HandlerFunc(SearchServerHandler))
 	defer searchService.Close()
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	result, err := searchClient.FindUsers(
 		SearchRequest{
@@ -456,7 +253,7 @@ func TestCorrectMaximumLimit(t *testing.T) {
 func TestQuery(t *testing.T) {
 	searchService := httptest.NewServer(http.HandlerFunc(SearchServerHandler))
 	defer searchService.Close()
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 	query := "consequat elit ipsum"
 
 	result, err := searchClient.FindUsers(
@@ -493,7 +290,7 @@ func TestInvalidJsonError(t *testing.T) {
 
 	defer searchService.Close()
 
-	searchClient := &SearchClient{testToken, searchService.URL}
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
 
 	_, err := searchClient.FindUsers(SearchRequest{})
 
@@ -503,3 +300,228 @@ func TestInvalidJsonError(t *testing.T) {
 		t.Error(InvalidErrorMsg)
 	}
 }
+
+func TestRetryServerErrors(t *testing.T) {
+	var calls int32
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		SearchServerHandler(w, r)
+	}))
+	defer searchService.Close()
+
+	searchClient := &SearchClient{
+		AccessToken: testToken,
+		URL:         searchService.URL,
+		Retry:       RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond},
+	}
+
+	result, err := searchClient.FindUsers(SearchRequest{Limit: 1, OrderField: "Id"})
+	if err != nil {
+		t.Fatal(fmt.Sprintf(ErrorIsNotNilFor, "retried request"))
+	}
+	if n := atomic.LoadInt32(&calls); len(result.Users) != 1 || n != 3 {
+		t.Errorf("expected 1 user after 3 calls, got %d users after %d calls", len(result.Users), n)
+	}
+
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var calls int32
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer searchService.Close()
+
+	searchClient := &SearchClient{
+		AccessToken: testToken,
+		URL:         searchService.URL,
+		Retry:       RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	}
+
+	_, err := searchClient.FindUsers(SearchRequest{})
+	if err == nil || err.Error() != FatalError {
+		t.Errorf("expected %q, got %v", FatalError, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expected 3 calls, got %d", n)
+	}
+}
+
+func TestFindUsersContextDeadline(t *testing.T) {
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer searchService.Close()
+
+	searchClient := &SearchClient{
+		AccessToken: testToken,
+		URL:         searchService.URL,
+		Retry:       RetryPolicy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := searchClient.FindUsersContext(ctx, SearchRequest{})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("deadline is not honoured, took %s", elapsed)
+	}
+}
+
+func TestRetryCanceledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer searchService.Close()
+
+	searchClient := &SearchClient{
+		AccessToken: testToken,
+		URL:         searchService.URL,
+		Retry:       RetryPolicy{MaxRetries: 3, BaseDelay: time.Minute},
+	}
+
+	_, err := searchClient.FindUsersContext(ctx, SearchRequest{})
+	var serverErr ErrServer
+	if !errors.Is(err, context.Canceled) || errors.As(err, &serverErr) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	expected := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for attempt, ms := range expected {
+		if d := p.delay(attempt); d != ms*time.Millisecond {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, ms*time.Millisecond, d)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.delay(2); d < 200*time.Millisecond || d > 400*time.Millisecond {
+			t.Fatalf("jittered delay %s out of range", d)
+		}
+	}
+}
+
+func TestIter(t *testing.T) {
+	searchService := httptest.NewServer(http.HandlerFunc(SearchServerHandler))
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	for _, prefetch := range []bool{false, true} {
+		it := searchClient.Iter(SearchRequest{Limit: 7, Offset: 3, OrderField: "Id", OrderBy: OrderByAsc})
+		it.Prefetch = prefetch
+
+		var ids []int
+		for it.Next() {
+			ids = append(ids, it.User().Id)
+		}
+		it.Close()
+
+		if it.Err() != nil {
+			t.Fatalf("prefetch %v: %s", prefetch, it.Err())
+		}
+		if len(ids) != 32 {
+			t.Fatalf("prefetch %v: expected 32 users, got %d", prefetch, len(ids))
+		}
+		for i, id := range ids {
+			if id != i+3 {
+				t.Fatalf("prefetch %v: expected id %d at %d, got %d", prefetch, i+3, i, id)
+			}
+		}
+	}
+}
+
+func TestIterError(t *testing.T) {
+	var calls int32
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) > 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		SearchServerHandler(w, r)
+	}))
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	it := searchClient.Iter(SearchRequest{Limit: 10, OrderField: "Id"})
+	it.Prefetch = true
+	defer it.Close()
+
+	var n int
+	for it.Next() {
+		n++
+	}
+	if n != 20 {
+		t.Errorf("expected 20 users before the error, got %d", n)
+	}
+	if it.Err() == nil || it.Err().Error() != FatalError {
+		t.Errorf("expected %q, got %v", FatalError, it.Err())
+	}
+	if it.Next() {
+		t.Error("Next after error")
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	searchService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("query") {
+		case "unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "order":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"Error": "`+ErrorBadOrderFieldMsg+`"}`)
+		case "unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, "maintenance")
+		case "slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer searchService.Close()
+
+	searchClient := &SearchClient{
+		AccessToken: testToken,
+		URL:         searchService.URL,
+		HTTPClient:  &http.Client{Timeout: 20 * time.Millisecond},
+	}
+
+	_, err := searchClient.FindUsers(SearchRequest{Query: "unauthorized"})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+
+	_, err = searchClient.FindUsers(SearchRequest{Query: "order", OrderField: "Email"})
+	var orderErr ErrBadOrderField
+	if !errors.As(err, &orderErr) || orderErr.Field != "Email" {
+		t.Errorf("expected ErrBadOrderField{Email}, got %v", err)
+	}
+
+	_, err = searchClient.FindUsers(SearchRequest{Query: "unavailable"})
+	var serverErr ErrServer
+	if !errors.As(err, &serverErr) || serverErr.Status != http.StatusServiceUnavailable || serverErr.Body != "maintenance" {
+		t.Errorf("expected ErrServer{503, maintenance}, got %v", err)
+	}
+
+	_, err = searchClient.FindUsers(SearchRequest{Query: "slow"})
+	var netErr net.Error
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected ErrTimeout wrapping the network error, got %v", err)
+	}
+}
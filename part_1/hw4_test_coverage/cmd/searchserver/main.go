@@ -0,0 +1,43 @@
+// searchserver - SearchServer как отдельный сервис, типы запросов и ответов общие с SearchClient
+//
+//	go build -o searchserver ./cmd/searchserver && ./searchserver -token secret -dataset dataset.xml
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	search "coursera/hw4_test_coverage"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	dataset := flag.String("dataset", "dataset.xml", "path to the users xml")
+	token := flag.String("token", "", "AccessToken expected from clients")
+	hmacSecret := flag.String("hmac-secret", "", "verify HMAC signed requests instead of AccessToken")
+	reload := flag.Duration("reload", 5*time.Second, "dataset change check interval, 0 disables hot reload")
+	flag.Parse()
+
+	if *token == "" && *hmacSecret == "" {
+		log.Fatal("-token or -hmac-secret is required")
+	}
+
+	srv, err := search.NewSearchServer(*dataset, *token)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *hmacSecret != "" {
+		srv.Auth = search.HMACVerifier{Secret: []byte(*hmacSecret)}
+	}
+
+	if *reload > 0 {
+		go srv.Watch(context.Background(), *reload)
+	}
+
+	log.Printf("starting server at %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
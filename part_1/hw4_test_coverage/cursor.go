@@ -0,0 +1,49 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var errBadCursor = errors.New("ErrorBadCursor")
+
+// cursor указывает на последнего пользователя страницы: ключи сортировки и Id
+type cursor struct {
+	Order string  `json:"o"`
+	Id    int     `json:"i"`
+	Age   int     `json:"a,omitempty"`
+	Name  string  `json:"n,omitempty"`
+	Score float64 `json:"s,omitempty"`
+}
+
+// cursorPage - ответ сервера в режиме курсора
+type cursorPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor"`
+}
+
+func encodeCursor(order []OrderKey, user *User, score float64) string {
+	data, _ := json.Marshal(cursor{EncodeOrder(order), user.Id, user.Age, user.Name, score})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor возвращает пользователя и релевантность из курсора, курсор должен быть выдан для той же сортировки
+func decodeCursor(s string, order []OrderKey) (*User, float64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, 0, errBadCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Order != EncodeOrder(order) {
+		return nil, 0, errBadCursor
+	}
+
+	return &User{Id: c.Id, Age: c.Age, Name: c.Name}, c.Score, nil
+}
+
+// cursorOrder - сортировка в режиме курсора, Id в конце делает порядок однозначным
+func cursorOrder(order []OrderKey) []OrderKey {
+	return append(order[:len(order):len(order)], OrderKey{Field: "Id"})
+}
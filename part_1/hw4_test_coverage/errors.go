@@ -0,0 +1,36 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrUnauthorized = errors.New("Bad AccessToken")
+	// ошибки таймаута оборачивают исходную сетевую ошибку
+	ErrTimeout = errors.New("timeout")
+	// курсор испорчен или выдан для другой сортировки
+	ErrBadCursor = errors.New("bad cursor")
+)
+
+type ErrBadOrderField struct {
+	Field string
+}
+
+func (e ErrBadOrderField) Error() string {
+	return fmt.Sprintf("OrderFeld %s invalid", e.Field)
+}
+
+// ErrServer - ответ сервера с кодом 5xx, после всех повторов
+type ErrServer struct {
+	Status int
+	Body   string
+}
+
+func (e ErrServer) Error() string {
+	if e.Status == http.StatusInternalServerError {
+		return "SearchServer fatal error"
+	}
+	return fmt.Sprintf("SearchServer fatal error: %d %s", e.Status, http.StatusText(e.Status))
+}
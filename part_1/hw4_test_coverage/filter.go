@@ -0,0 +1,86 @@
+package search
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func encodeIds(ids []int) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseFilters заполняет фильтры req из age_min, age_max, gender и id_in
+func parseFilters(params url.Values, req *SearchRequest) error {
+	var err error
+	if v := params.Get("age_min"); v != "" {
+		if req.AgeMin, err = strconv.Atoi(v); err != nil || req.AgeMin < 0 {
+			return errors.New("age_min must be >= 0")
+		}
+	}
+	if v := params.Get("age_max"); v != "" {
+		if req.AgeMax, err = strconv.Atoi(v); err != nil || req.AgeMax < 0 {
+			return errors.New("age_max must be >= 0")
+		}
+	}
+	if req.AgeMax > 0 && req.AgeMin > req.AgeMax {
+		return errors.New("age_min must be <= age_max")
+	}
+
+	switch req.Gender = params.Get("gender"); req.Gender {
+	case "", "male", "female":
+	default:
+		return errors.New("gender must be male or female")
+	}
+
+	if v := params.Get("id_in"); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return errors.New("id_in must be a comma separated list of ids")
+			}
+			req.IdIn = append(req.IdIn, id)
+		}
+	}
+
+	return nil
+}
+
+type userFilter struct {
+	req SearchRequest
+	ids map[int]bool
+	// Query ищется по индексу, а не подстрокой
+	fullText bool
+}
+
+func newUserFilter(req SearchRequest) *userFilter {
+	f := &userFilter{req: req, fullText: usesRelevance(req.Order)}
+	if len(req.IdIn) > 0 {
+		f.ids = make(map[int]bool, len(req.IdIn))
+		for _, id := range req.IdIn {
+			f.ids[id] = true
+		}
+	}
+	return f
+}
+
+func (f *userFilter) match(user *User) bool {
+	switch {
+	case !f.fullText && f.req.Query != "" && !strings.Contains(user.Name, f.req.Query) && !strings.Contains(user.About, f.req.Query):
+		return false
+	case user.Age < f.req.AgeMin:
+		return false
+	case f.req.AgeMax > 0 && user.Age > f.req.AgeMax:
+		return false
+	case f.req.Gender != "" && user.Gender != f.req.Gender:
+		return false
+	case f.ids != nil && !f.ids[user.Id]:
+		return false
+	}
+	return true
+}
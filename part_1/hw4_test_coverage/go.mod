@@ -0,0 +1,3 @@
+module coursera/hw4_test_coverage
+
+go 1.17
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"context"
+)
+
+// UserIterator проходит по всем страницам результатов поиска
+//
+//	it := srv.Iter(SearchRequest{Query: "ipsum", OrderField: "Id"})
+//	defer it.Close()
+//	for it.Next() {
+//		fmt.Println(it.User().Name)
+//	}
+//	if err := it.Err(); err != nil { ... }
+type UserIterator struct {
+	// если true, следующая страница запрашивается параллельно с обработкой текущей.
+	// Надо выставить до первого вызова Next
+	Prefetch bool
+
+	srv    *SearchClient
+	ctx    context.Context
+	cancel context.CancelFunc
+	req    SearchRequest
+
+	page    []User
+	pos     int
+	more    bool
+	pending chan pageResult
+
+	user User
+	err  error
+}
+
+type pageResult struct {
+	resp *SearchResponse
+	err  error
+}
+
+// Iter возвращает итератор по всем пользователям начиная с req.Offset, req.Limit - размер страницы
+func (srv *SearchClient) Iter(req SearchRequest) *UserIterator {
+	return srv.IterContext(context.Background(), req)
+}
+
+func (srv *SearchClient) IterContext(ctx context.Context, req SearchRequest) *UserIterator {
+	if req.Limit <= 0 || req.Limit > 25 {
+		req.Limit = 25
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &UserIterator{srv: srv, ctx: ctx, cancel: cancel, req: req, more: true}
+}
+
+func (it *UserIterator) Next() bool {
+	for it.pos >= len(it.page) {
+		if !it.more || it.err != nil {
+			return false
+		}
+		it.nextPage()
+	}
+
+	it.user = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+func (it *UserIterator) User() User {
+	return it.user
+}
+
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+// Close останавливает предзагрузку, если итератор не дочитан до конца
+func (it *UserIterator) Close() {
+	it.cancel()
+}
+
+func (it *UserIterator) nextPage() {
+	var res pageResult
+	if it.pending != nil {
+		res = <-it.pending
+		it.pending = nil
+	} else {
+		res = it.fetch(it.req)
+	}
+
+	if res.err != nil {
+		it.err = res.err
+		return
+	}
+
+	it.page, it.pos = res.resp.Users, 0
+	// пустая страница с NextPage означала бы вечный цикл
+	it.more = res.resp.NextPage && len(it.page) > 0
+	if it.req.UseCursor || it.req.Cursor != "" {
+		it.req.Cursor = res.resp.NextCursor
+	} else {
+		it.req.Offset += len(it.page)
+	}
+
+	if it.more && it.Prefetch {
+		it.pending = make(chan pageResult, 1)
+		go func(req SearchRequest, pending chan<- pageResult) {
+			pending <- it.fetch(req)
+		}(it.req, it.pending)
+	}
+}
+
+func (it *UserIterator) fetch(req SearchRequest) pageResult {
+	resp, err := it.srv.FindUsersContext(it.ctx, req)
+	return pageResult{resp, err}
+}
@@ -0,0 +1,65 @@
+package search
+
+import (
+	"errors"
+	"strings"
+)
+
+// OrderKey - одно поле сортировки, на проводе "Age:desc"
+type OrderKey struct {
+	Field string
+	Desc  bool
+}
+
+// OrderFieldRelevance включает полнотекстовый поиск: Query ищется по словам без учета регистра,
+// слово запроса совпадает со всеми словами, которые с него начинаются, результаты ранжируются по BM25.
+// Направление по умолчанию - desc, самые релевантные первыми
+const OrderFieldRelevance = "Relevance"
+
+var orderFields = map[string]bool{"Id": true, "Age": true, "Name": true, OrderFieldRelevance: true}
+
+var errBadOrderDirection = errors.New("order direction must be asc or desc")
+
+// ParseOrder разбирает "Age:desc,Name:asc", направление по умолчанию asc
+func ParseOrder(s string) ([]OrderKey, error) {
+	var keys []OrderKey
+	for _, part := range strings.Split(s, ",") {
+		field, dir, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if !orderFields[field] {
+			return nil, errors.New("ErrorBadOrderField")
+		}
+
+		key := OrderKey{Field: field}
+		switch dir {
+		case "":
+			key.Desc = field == OrderFieldRelevance
+		case "asc":
+		case "desc":
+			key.Desc = true
+		default:
+			return nil, errBadOrderDirection
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func EncodeOrder(keys []OrderKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Field + ":asc"
+		if key.Desc {
+			parts[i] = key.Field + ":desc"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func usesRelevance(order []OrderKey) bool {
+	for _, key := range order {
+		if key.Field == OrderFieldRelevance {
+			return true
+		}
+	}
+	return false
+}
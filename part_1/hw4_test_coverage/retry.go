@@ -0,0 +1,62 @@
+package search
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy задает повторы запросов при таймаутах и 5xx ответах.
+// Задержка перед повтором n равна BaseDelay*2^n, но не больше MaxDelay,
+// и уменьшается на случайную долю до Jitter, чтобы клиенты не повторяли запросы одновременно.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// от 0 до 1
+	Jitter float64
+}
+
+// DefaultRetryPolicy - разумные значения для включения повторов
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   2 * time.Second,
+	Jitter:     0.5,
+}
+
+func (p RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		netErr, ok := err.(net.Error)
+		return ok && netErr.Timeout()
+	}
+	return resp.StatusCode >= 500
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.delay(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
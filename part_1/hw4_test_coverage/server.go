@@ -0,0 +1,371 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type XmlUser struct {
+	Id        int    `xml:"id"`
+	FirstName string `xml:"first_name"`
+	LastName  string `xml:"last_name"`
+	Age       int    `xml:"age"`
+	About     string `xml:"about"`
+	Gender    string `xml:"gender"`
+}
+
+type XmlUsers struct {
+	XMLName xml.Name  `xml:"root"`
+	Users   []XmlUser `xml:"row"`
+}
+
+// SearchServer отвечает на запросы SearchClient, данные читаются из xml один раз и перечитываются через Reload
+type SearchServer struct {
+	// пустой токен не принимается никогда
+	AccessToken string
+	// если задан, используется вместо проверки AccessToken
+	Auth Verifier
+
+	path string
+
+	mu      sync.RWMutex
+	users   []User
+	index   *textIndex
+	size    int64
+	modTime time.Time
+}
+
+func NewSearchServer(path, accessToken string) (*SearchServer, error) {
+	srv := &SearchServer{AccessToken: accessToken, path: path}
+	if err := srv.Reload(); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+// Reload перечитывает файл, при ошибке остаются старые данные
+func (srv *SearchServer) Reload() error {
+	file, err := os.Open(srv.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	var usersList XmlUsers
+	if err := xml.NewDecoder(file).Decode(&usersList); err != nil {
+		return fmt.Errorf("%s: %w", srv.path, err)
+	}
+
+	users := make([]User, len(usersList.Users))
+	for i, u := range usersList.Users {
+		users[i] = User{
+			Id:     u.Id,
+			Name:   u.FirstName + " " + u.LastName,
+			Age:    u.Age,
+			About:  u.About,
+			Gender: u.Gender,
+		}
+	}
+
+	srv.mu.Lock()
+	srv.users, srv.index, srv.size, srv.modTime = users, newTextIndex(users), stat.Size(), stat.ModTime()
+	srv.mu.Unlock()
+
+	return nil
+}
+
+func (srv *SearchServer) changed() bool {
+	stat, err := os.Stat(srv.path)
+	if err != nil {
+		return false
+	}
+
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+	return stat.Size() != srv.size || !stat.ModTime().Equal(srv.modTime)
+}
+
+// Watch проверяет файл каждые interval и перечитывает его при изменении, пока не отменен ctx
+func (srv *SearchServer) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !srv.changed() {
+			continue
+		}
+		if err := srv.Reload(); err != nil {
+			log.Printf("reload failed: %s", err)
+		}
+	}
+}
+
+func (srv *SearchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := srv.Auth
+	if auth == nil {
+		auth = StaticTokens{srv.AccessToken}
+	}
+	RequireAuth(auth, http.HandlerFunc(srv.serveSearch)).ServeHTTP(w, r)
+}
+
+func (srv *SearchServer) serveSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	req, err := parseSearchRequest(r)
+	if err != nil {
+		writeSearchError(w, err.Error())
+		return
+	}
+
+	users, next, err := srv.search(req)
+	if err != nil {
+		writeSearchError(w, err.Error())
+		return
+	}
+
+	var usersJson []byte
+	if req.UseCursor {
+		usersJson, err = json.Marshal(cursorPage{users, next})
+	} else {
+		usersJson, err = json.Marshal(users)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(usersJson)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Write(usersJson)
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeSearchError(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(SearchErrorResponse{msg})
+}
+
+func parseSearchRequest(r *http.Request) (SearchRequest, error) {
+	params := r.URL.Query()
+	req := SearchRequest{
+		Query:      params.Get("query"),
+		OrderField: params.Get("order_field"),
+	}
+
+	var err error
+	if req.Limit, err = strconv.Atoi(params.Get("limit")); err != nil || req.Limit < 0 {
+		return req, errors.New("limit must be >= 0")
+	}
+	if req.Offset, err = strconv.Atoi(params.Get("offset")); err != nil || req.Offset < 0 {
+		return req, errors.New("offset must be >= 0")
+	}
+	if req.OrderBy, err = strconv.Atoi(params.Get("order_by")); err != nil || req.OrderBy < OrderByAsc || req.OrderBy > OrderByDesc {
+		return req, errors.New("order_by must be -1, 0 or 1")
+	}
+
+	if err := parseFilters(params, &req); err != nil {
+		return req, err
+	}
+
+	req.UseCursor = params.Has("cursor")
+	req.Cursor = params.Get("cursor")
+
+	if order := params.Get("order"); order != "" {
+		req.Order, err = ParseOrder(order)
+		return req, err
+	}
+
+	if req.OrderField == "" {
+		req.OrderField = "Name"
+	}
+	if !orderFields[req.OrderField] {
+		return req, errors.New("ErrorBadOrderField")
+	}
+	if req.OrderBy != OrderByAsIs {
+		req.Order = []OrderKey{{req.OrderField, req.OrderBy == OrderByDesc}}
+	} else if req.OrderField == OrderFieldRelevance {
+		req.Order = []OrderKey{{req.OrderField, true}}
+	}
+
+	return req, nil
+}
+
+// scoredUser - найденный пользователь и его релевантность, Id в файле могут повторяться
+type scoredUser struct {
+	User
+	score float64
+}
+
+// search возвращает страницу пользователей и, в режиме курсора, курсор следующей страницы
+func (srv *SearchServer) search(req SearchRequest) ([]User, string, error) {
+	srv.mu.RLock()
+	all, index := srv.users, srv.index
+	srv.mu.RUnlock()
+
+	filter := newUserFilter(req)
+
+	var scores map[int]float64
+	if filter.fullText && req.Query != "" {
+		if len(tokenize(req.Query)) == 0 {
+			return nil, "", errors.New("query must contain letters or digits")
+		}
+		scores = index.search(req.Query)
+	}
+
+	// all не меняется, сортируется только копия
+	found := make([]scoredUser, 0, len(all))
+	for i := range all {
+		score, ok := scores[i]
+		if (scores != nil && !ok) || !filter.match(&all[i]) {
+			continue
+		}
+		found = append(found, scoredUser{all[i], score})
+	}
+
+	if !req.UseCursor {
+		sortUsers(found, req.Order)
+
+		if req.Offset > len(found) {
+			return []User{}, "", nil
+		}
+		found = found[req.Offset:]
+		if req.Limit < len(found) {
+			found = found[:req.Limit]
+		}
+		return usersOf(found), "", nil
+	}
+
+	// в режиме курсора Offset не используется
+	order := cursorOrder(req.Order)
+	sortUsers(found, order)
+
+	if req.Cursor != "" {
+		last, score, err := decodeCursor(req.Cursor, order)
+		if err != nil {
+			return nil, "", err
+		}
+		start := sort.Search(len(found), func(i int) bool {
+			return compareByOrder(&found[i].User, last, found[i].score, score, order) > 0
+		})
+		found = found[start:]
+	}
+
+	var next string
+	if req.Limit < len(found) {
+		found = found[:req.Limit]
+		if req.Limit > 0 {
+			last := &found[len(found)-1]
+			next = encodeCursor(order, &last.User, last.score)
+		}
+	}
+
+	return usersOf(found), next, nil
+}
+
+func usersOf(found []scoredUser) []User {
+	users := make([]User, len(found))
+	for i := range found {
+		users[i] = found[i].User
+	}
+	return users
+}
+
+func compareUsers(a, b *User, field string) int {
+	switch field {
+	case "Age":
+		return compareInts(a.Age, b.Age)
+	case "Name":
+		return strings.Compare(a.Name, b.Name)
+	}
+	return compareInts(a.Id, b.Id)
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareByOrder сравнивает по первому ключу, при равенстве - по следующим. sa и sb - релевантность a и b
+func compareByOrder(a, b *User, sa, sb float64, order []OrderKey) int {
+	for _, key := range order {
+		var c int
+		if key.Field == OrderFieldRelevance {
+			c = compareFloats(sa, sb)
+		} else {
+			c = compareUsers(a, b, key.Field)
+		}
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortUsers сортирует стабильно, пользователи равные по всем ключам остаются в порядке файла
+func sortUsers(users []scoredUser, order []OrderKey) {
+	if len(order) == 0 {
+		return
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		a, b := &users[i], &users[j]
+		return compareByOrder(&a.User, &b.User, a.score, b.score, order) < 0
+	})
+}
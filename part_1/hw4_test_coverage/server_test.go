@@ -0,0 +1,415 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const serverTestXml = `<root>
+<row><id>1</id><first_name>Bob</first_name><last_name>Z</last_name><age>30</age><about>likes go</about><gender>male</gender></row>
+<row><id>2</id><first_name>Ann</first_name><last_name>Y</last_name><age>25</age><about>likes rust</about><gender>female</gender></row>
+</root>`
+
+func newTestSearchServer(t *testing.T, data string) (*SearchServer, string) {
+	path := filepath.Join(t.TempDir(), "dataset.xml")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewSearchServer(path, testToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, path
+}
+
+func TestSearchServerDefaults(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	result, err := searchClient.FindUsers(SearchRequest{Limit: 10, OrderBy: OrderByAsc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 2 || result.Users[0].Name != "Ann Y" || result.Users[1].Name != "Bob Z" {
+		t.Errorf("expected users ordered by Name, got %+v", result.Users)
+	}
+
+	result, err = searchClient.FindUsers(SearchRequest{Limit: 10, Offset: 5})
+	if err != nil || len(result.Users) != 0 || result.NextPage {
+		t.Errorf("expected empty page, got %+v %v", result, err)
+	}
+}
+
+func TestSearchServerValidation(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+
+	cases := map[string]string{
+		"limit=x&offset=0&order_by=0":                   "limit must be >= 0",
+		"limit=1&offset=-1&order_by=0":                  "offset must be >= 0",
+		"limit=1&offset=0&order_by=2":                   "order_by must be -1, 0 or 1",
+		"limit=1&offset=0&order_by=0&order_field=About": "ErrorBadOrderField",
+	}
+
+	for query, expected := range cases {
+		r := httptest.NewRequest("GET", "/?"+query, nil)
+		r.Header.Set("AccessToken", testToken)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+
+		var resp SearchErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusBadRequest || resp.Error != expected {
+			t.Errorf("%s: expected 400 %q, got %d %s", query, expected, w.Code, w.Body)
+		}
+	}
+}
+
+func TestSearchServerHotReload(t *testing.T) {
+	srv, path := newTestSearchServer(t, serverTestXml)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Watch(ctx, 5*time.Millisecond)
+
+	updated := serverTestXml[:len(serverTestXml)-len("</root>")] +
+		`<row><id>3</id><first_name>Cid</first_name><last_name>X</last_name><age>40</age><about></about><gender>male</gender></row></root>`
+	if err := os.WriteFile(path, []byte(updated), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func() int {
+		users, _, _ := srv.search(SearchRequest{Limit: 10})
+		return len(users)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for count() != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("dataset is not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// сломанный файл не заменяет загруженные данные
+	if err := os.WriteFile(path, []byte("<root><row>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Reload(); err == nil {
+		t.Error("expected decoding error")
+	}
+	if n := count(); n != 3 {
+		t.Errorf("expected 3 users after failed reload, got %d", n)
+	}
+}
+
+func TestSearchServerMultiKeyOrder(t *testing.T) {
+	srv, _ := newTestSearchServer(t, `<root>
+<row><id>1</id><first_name>Bob</first_name><age>30</age></row>
+<row><id>2</id><first_name>Ann</first_name><age>25</age></row>
+<row><id>3</id><first_name>Cid</first_name><age>30</age></row>
+<row><id>4</id><first_name>Ann</first_name><age>30</age></row>
+<row><id>5</id><first_name>Ann</first_name><age>30</age></row>
+</root>`)
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	cases := []struct {
+		req      SearchRequest
+		expected []int
+	}{
+		{SearchRequest{Order: []OrderKey{{"Age", true}, {"Name", false}}}, []int{4, 5, 1, 3, 2}},
+		{SearchRequest{Order: []OrderKey{{"Name", true}, {"Id", true}}}, []int{3, 1, 5, 4, 2}},
+		// равные по ключу остаются в порядке файла
+		{SearchRequest{OrderField: "Age", OrderBy: OrderByDesc}, []int{1, 3, 4, 5, 2}},
+		{SearchRequest{OrderField: "Age", OrderBy: OrderByAsIs}, []int{1, 2, 3, 4, 5}},
+	}
+
+	for _, c := range cases {
+		c.req.Limit = 10
+		result, err := searchClient.FindUsers(c.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]int, len(result.Users))
+		for i, user := range result.Users {
+			ids[i] = user.Id
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(c.expected) {
+			t.Errorf("%+v: expected %v, got %v", c.req, c.expected, ids)
+		}
+	}
+
+	_, err := searchClient.FindUsers(SearchRequest{Order: []OrderKey{{"Age", true}, {"About", false}}})
+	var orderErr ErrBadOrderField
+	if !errors.As(err, &orderErr) || orderErr.Field != "Age:desc,About:asc" {
+		t.Errorf("expected ErrBadOrderField, got %v", err)
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	keys, err := ParseOrder("Age:desc, Name,Id:asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if EncodeOrder(keys) != "Age:desc,Name:asc,Id:asc" {
+		t.Errorf("bad keys %+v", keys)
+	}
+
+	for _, s := range []string{"", "Age:up", "Email:asc", "Age:desc,"} {
+		if _, err := ParseOrder(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestSearchServerFilters(t *testing.T) {
+	srv, _ := newTestSearchServer(t, `<root>
+<row><id>1</id><age>20</age><gender>male</gender></row>
+<row><id>2</id><age>25</age><gender>female</gender></row>
+<row><id>3</id><age>30</age><gender>male</gender></row>
+<row><id>4</id><age>35</age><gender>female</gender></row>
+</root>`)
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	cases := []struct {
+		req      SearchRequest
+		expected []int
+	}{
+		{SearchRequest{AgeMin: 25}, []int{2, 3, 4}},
+		{SearchRequest{AgeMin: 21, AgeMax: 30}, []int{2, 3}},
+		{SearchRequest{Gender: "female"}, []int{2, 4}},
+		{SearchRequest{IdIn: []int{4, 1, 9}}, []int{1, 4}},
+		{SearchRequest{AgeMax: 30, Gender: "male", IdIn: []int{3, 4}}, []int{3}},
+	}
+
+	for _, c := range cases {
+		c.req.Limit = 10
+		result, err := searchClient.FindUsers(c.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]int, len(result.Users))
+		for i, user := range result.Users {
+			ids[i] = user.Id
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(c.expected) {
+			t.Errorf("%+v: expected %v, got %v", c.req, c.expected, ids)
+		}
+	}
+}
+
+func TestSearchServerFilterValidation(t *testing.T) {
+	srv, _ := newTestSearchServer(t, serverTestXml)
+
+	cases := map[string]string{
+		"age_min=-1":            "age_min must be >= 0",
+		"age_max=old":           "age_max must be >= 0",
+		"age_min=30&age_max=20": "age_min must be <= age_max",
+		"gender=other":          "gender must be male or female",
+		"id_in=1,two":           "id_in must be a comma separated list of ids",
+	}
+
+	for query, expected := range cases {
+		r := httptest.NewRequest("GET", "/?limit=1&offset=0&order_by=0&"+query, nil)
+		r.Header.Set("AccessToken", testToken)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+
+		var resp SearchErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusBadRequest || resp.Error != expected {
+			t.Errorf("%s: expected 400 %q, got %d %s", query, expected, w.Code, w.Body)
+		}
+	}
+}
+
+func TestSearchServerRelevance(t *testing.T) {
+	srv, _ := newTestSearchServer(t, `<root>
+<row><id>1</id><first_name>Ann</first_name><about>Go, go and more Go</about></row>
+<row><id>2</id><first_name>Bob</first_name><about>writes golang and rust every day of the week</about></row>
+<row><id>3</id><first_name>Cid</first_name><about>only rust</about></row>
+<row><id>4</id><first_name>Gordon</first_name><about>python</about></row>
+</root>`)
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	cases := []struct {
+		req      SearchRequest
+		expected []int
+	}{
+		// префикс и регистр: go совпадает с Go, golang и Gordon
+		{SearchRequest{Query: "GO", OrderField: OrderFieldRelevance}, []int{1, 4, 2}},
+		{SearchRequest{Query: "go rust", OrderField: OrderFieldRelevance}, []int{2}},
+		{SearchRequest{Query: "rust", Order: []OrderKey{{OrderFieldRelevance, false}}}, []int{2, 3}},
+		{SearchRequest{Query: "nothing", OrderField: OrderFieldRelevance}, []int{}},
+		// без Relevance Query остается подстрокой с учетом регистра
+		{SearchRequest{Query: "Go", OrderField: "Id", OrderBy: OrderByAsc}, []int{1, 4}},
+	}
+
+	for _, c := range cases {
+		c.req.Limit = 10
+		result, err := searchClient.FindUsers(c.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]int, len(result.Users))
+		for i, user := range result.Users {
+			ids[i] = user.Id
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(c.expected) {
+			t.Errorf("%+v: expected %v, got %v", c.req, c.expected, ids)
+		}
+	}
+}
+
+func TestSearchServerRelevanceDuplicateIds(t *testing.T) {
+	srv, _ := newTestSearchServer(t, `<root>
+<row><id>1</id><first_name>Bob</first_name><about>rust and a long story that mentions go only once</about></row>
+<row><id>1</id><first_name>Ann</first_name><about>go go go</about></row>
+</root>`)
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	result, err := searchClient.FindUsers(SearchRequest{Limit: 10, Query: "go", OrderField: OrderFieldRelevance})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Users) != 2 || result.Users[0].Name != "Ann " || result.Users[1].Name != "Bob " {
+		t.Errorf("users with the same Id got the same relevance: %+v", result.Users)
+	}
+
+	// в запросе нет ни одного слова, раньше находились все пользователи
+	_, err = searchClient.FindUsers(SearchRequest{Limit: 10, Query: "?!", OrderField: OrderFieldRelevance})
+	if err == nil || err.Error() != "unknown bad request error: query must contain letters or digits" {
+		t.Errorf("expected bad request, got %v", err)
+	}
+}
+
+func TestTextIndex(t *testing.T) {
+	ix := newTextIndex([]User{
+		{Name: "Ann Lee", About: "Tea, coffee; TEA!"},
+		{Name: "Bob", About: "coffee"},
+	})
+
+	if tokens := tokenize("Tea, coffee; TEA!"); fmt.Sprint(tokens) != "[tea coffee tea]" {
+		t.Errorf("bad tokens %v", tokens)
+	}
+	if terms := ix.withPrefix("co"); fmt.Sprint(terms) != "[coffee]" {
+		t.Errorf("bad prefix terms %v", terms)
+	}
+
+	scores := ix.search("te")
+	if len(scores) != 1 || scores[0] <= 0 {
+		t.Errorf("bad scores %v", scores)
+	}
+	if scores := ix.search("coffee"); len(scores) != 2 || scores[1] <= scores[0] {
+		t.Errorf("shorter document must score higher, got %v", scores)
+	}
+	if ix.search("!!") != nil {
+		t.Error("query without words must not filter")
+	}
+}
+
+func TestSearchServerCursor(t *testing.T) {
+	srv, path := newTestSearchServer(t, `<root>
+<row><id>1</id><first_name>Bob</first_name><age>30</age></row>
+<row><id>2</id><first_name>Ann</first_name><age>25</age></row>
+<row><id>3</id><first_name>Cid</first_name><age>30</age></row>
+<row><id>4</id><first_name>Dan</first_name><age>20</age></row>
+<row><id>5</id><first_name>Eve</first_name><age>30</age></row>
+</root>`)
+	searchService := httptest.NewServer(srv)
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	req := SearchRequest{Limit: 2, Order: []OrderKey{{"Age", true}}, UseCursor: true}
+	first, err := searchClient.FindUsers(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Users) != 2 || first.Users[0].Id != 1 || first.Users[1].Id != 3 || first.NextCursor == "" {
+		t.Fatalf("bad first page %+v", first)
+	}
+
+	// новый пользователь перед курсором не сдвигает следующую страницу
+	if err := os.WriteFile(path, []byte(`<root>
+<row><id>0</id><first_name>Zed</first_name><age>40</age></row>
+<row><id>1</id><first_name>Bob</first_name><age>30</age></row>
+<row><id>2</id><first_name>Ann</first_name><age>25</age></row>
+<row><id>3</id><first_name>Cid</first_name><age>30</age></row>
+<row><id>4</id><first_name>Dan</first_name><age>20</age></row>
+<row><id>5</id><first_name>Eve</first_name><age>30</age></row>
+</root>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Cursor = first.NextCursor
+	second, err := searchClient.FindUsers(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Users) != 2 || second.Users[0].Id != 5 || second.Users[1].Id != 2 || !second.NextPage {
+		t.Fatalf("bad second page %+v", second)
+	}
+
+	req.Cursor = second.NextCursor
+	third, err := searchClient.FindUsers(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(third.Users) != 1 || third.Users[0].Id != 4 || third.NextPage || third.NextCursor != "" {
+		t.Fatalf("bad last page %+v", third)
+	}
+
+	req.Order = []OrderKey{{"Name", false}}
+	if _, err := searchClient.FindUsers(req); !errors.Is(err, ErrBadCursor) {
+		t.Errorf("cursor of another order: expected ErrBadCursor, got %v", err)
+	}
+	req.Cursor = "garbage"
+	if _, err := searchClient.FindUsers(req); !errors.Is(err, ErrBadCursor) {
+		t.Errorf("expected ErrBadCursor, got %v", err)
+	}
+}
+
+func TestIterCursor(t *testing.T) {
+	searchService := httptest.NewServer(http.HandlerFunc(SearchServerHandler))
+	defer searchService.Close()
+	searchClient := &SearchClient{AccessToken: testToken, URL: searchService.URL}
+
+	it := searchClient.Iter(SearchRequest{Limit: 4, Order: []OrderKey{{"Age", false}}, UseCursor: true})
+	it.Prefetch = true
+	defer it.Close()
+
+	seen := make(map[int]bool)
+	var prev User
+	for it.Next() {
+		user := it.User()
+		if seen[user.Id] || (len(seen) > 0 && (user.Age < prev.Age || user.Age == prev.Age && user.Id < prev.Id)) {
+			t.Fatalf("bad order: %d after %d", user.Id, prev.Id)
+		}
+		seen[user.Id], prev = true, user
+	}
+	if it.Err() != nil || len(seen) != 35 {
+		t.Errorf("expected 35 users, got %d, %v", len(seen), it.Err())
+	}
+}
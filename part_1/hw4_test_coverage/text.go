@@ -0,0 +1,113 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+type posting struct {
+	doc int
+	tf  int
+}
+
+// textIndex - инвертированный индекс по Name и About
+type textIndex struct {
+	// отсортированы для поиска по префиксу
+	terms    []string
+	postings map[string][]posting
+	docLen   []int
+	avgLen   float64
+}
+
+// tokenize приводит текст к нижнему регистру и делит по всему, что не буква и не цифра
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func newTextIndex(users []User) *textIndex {
+	ix := &textIndex{
+		postings: make(map[string][]posting),
+		docLen:   make([]int, len(users)),
+	}
+
+	var total int
+	for doc := range users {
+		tokens := tokenize(users[doc].Name + " " + users[doc].About)
+		ix.docLen[doc] = len(tokens)
+		total += len(tokens)
+
+		tf := make(map[string]int)
+		for _, token := range tokens {
+			tf[token]++
+		}
+		for term, n := range tf {
+			ix.postings[term] = append(ix.postings[term], posting{doc, n})
+		}
+	}
+
+	ix.terms = make([]string, 0, len(ix.postings))
+	for term := range ix.postings {
+		ix.terms = append(ix.terms, term)
+	}
+	sort.Strings(ix.terms)
+
+	if len(users) > 0 {
+		ix.avgLen = float64(total) / float64(len(users))
+	}
+	return ix
+}
+
+// withPrefix возвращает термы, начинающиеся с prefix
+func (ix *textIndex) withPrefix(prefix string) []string {
+	start := sort.SearchStrings(ix.terms, prefix)
+	end := start
+	for end < len(ix.terms) && strings.HasPrefix(ix.terms[end], prefix) {
+		end++
+	}
+	return ix.terms[start:end]
+}
+
+// search возвращает BM25 документов, в которых есть все слова запроса.
+// Слово запроса совпадает с любым термом, который с него начинается
+func (ix *textIndex) search(query string) map[int]float64 {
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var scores map[int]float64
+	for _, token := range tokens {
+		tokenScores := make(map[int]float64)
+		for _, term := range ix.withPrefix(token) {
+			list := ix.postings[term]
+			idf := math.Log(1 + (float64(len(ix.docLen))-float64(len(list))+0.5)/(float64(len(list))+0.5))
+			for _, p := range list {
+				norm := bm25K1 * (1 - bm25B + bm25B*float64(ix.docLen[p.doc])/ix.avgLen)
+				tokenScores[p.doc] += idf * float64(p.tf) * (bm25K1 + 1) / (float64(p.tf) + norm)
+			}
+		}
+
+		if scores == nil {
+			scores = tokenScores
+			continue
+		}
+		for doc, score := range scores {
+			if s, ok := tokenScores[doc]; ok {
+				scores[doc] = score + s
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	return scores
+}